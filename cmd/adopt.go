@@ -0,0 +1,69 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/spf13/cobra"
+
+	"github.com/bwagner5/vpcctl/pkg/vpc"
+)
+
+type AdoptOptions struct {
+	VPCID string `yaml:"vpcID"`
+	Name  string `yaml:"name"`
+}
+
+var (
+	adoptOpts = AdoptOptions{}
+	cmdAdopt  = &cobra.Command{
+		Use:   "adopt --vpc-id vpc-123 --name my-vpc",
+		Short: "Adopt a VPC",
+		Long:  `Tag a VPC that was not created by vpcctl and its subnets, route tables and gateways so that it can be managed with vpcctl`,
+		Args:  cobra.MinimumNArgs(0),
+		Run: func(cmd *cobra.Command, _ []string) {
+			opts, err := ParseConfig(globalOpts, adoptOpts)
+			if err != nil {
+				fmt.Printf("Error parsing config file (%s): %s", globalOpts.ConfigFile, err)
+			}
+			if globalOpts.Verbose {
+				fmt.Println(PrettyEncode(opts))
+			}
+			cfg, err := config.LoadDefaultConfig(cmd.Context())
+			if err != nil {
+				fmt.Printf("Error getting AWS config: %s", err)
+				os.Exit(1)
+			}
+
+			vpcClient := NewVPCClient(cfg)
+			adoptDetails, err := vpcClient.Adopt(cmd.Context(), vpc.AdoptOptions{VPCID: opts.VPCID, Name: opts.Name})
+			if err != nil {
+				fmt.Println(PrettyEncode(adoptDetails))
+				fmt.Println(err)
+				os.Exit(2)
+			}
+			fmt.Println(PrettyEncode(adoptDetails))
+		},
+	}
+)
+
+func init() {
+	cmdAdopt.Flags().StringVar(&adoptOpts.VPCID, "vpc-id", "", "ID of the VPC to adopt")
+	cmdAdopt.Flags().StringVarP(&adoptOpts.Name, "name", "n", "", "Name to manage the VPC as")
+	rootCmd.AddCommand(cmdAdopt)
+}
@@ -0,0 +1,85 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/spf13/cobra"
+
+	"github.com/bwagner5/vpcctl/pkg/vpc"
+)
+
+type CloneOptions struct {
+	Name    string `yaml:"name"`
+	NewName string `yaml:"newName"`
+	Region  string `yaml:"region"`
+	RoleARN string `yaml:"roleARN"`
+	CIDR    string `yaml:"cidr"`
+}
+
+var (
+	cloneOpts = CloneOptions{}
+	cmdClone  = &cobra.Command{
+		Use:   "clone --name my-vpc --new-name my-other-vpc [--region us-east-2]",
+		Short: "Clone a VPC",
+		Long:  `Create a VPC with the same layout as an existing VPC, optionally in another region or account and with another CIDR`,
+		Args:  cobra.MinimumNArgs(0),
+		Run: func(cmd *cobra.Command, _ []string) {
+			opts, err := ParseConfig(globalOpts, cloneOpts)
+			if err != nil {
+				fmt.Printf("Error parsing config file (%s): %s", globalOpts.ConfigFile, err)
+			}
+			if globalOpts.Verbose {
+				fmt.Println(PrettyEncode(opts))
+			}
+			cfg, err := config.LoadDefaultConfig(cmd.Context())
+			if err != nil {
+				fmt.Printf("Error getting AWS config: %s", err)
+				os.Exit(1)
+			}
+
+			vpcClient := NewVPCClient(cfg)
+			vpcDetails, err := vpcClient.Clone(cmd.Context(), CloneCLIOptsToVPCOpts(opts))
+			if err != nil {
+				fmt.Println(PrettyEncode(vpcDetails))
+				fmt.Println(err)
+				os.Exit(2)
+			}
+			fmt.Println(PrettyEncode(vpcDetails))
+		},
+	}
+)
+
+func init() {
+	cmdClone.Flags().StringVarP(&cloneOpts.Name, "name", "n", "", "Name of the VPC to clone")
+	cmdClone.Flags().StringVar(&cloneOpts.NewName, "new-name", "", "Name of the new VPC")
+	cmdClone.Flags().StringVar(&cloneOpts.Region, "region", "", "Region to create the new VPC in (defaults to the current region)")
+	cmdClone.Flags().StringVar(&cloneOpts.RoleARN, "role-arn", "", "IAM role to assume to create the new VPC in another account")
+	cmdClone.Flags().StringVarP(&cloneOpts.CIDR, "cidr", "c", "", "CIDR of the new VPC, subnet CIDRs are re-based onto it (defaults to the source VPC's CIDR)")
+	rootCmd.AddCommand(cmdClone)
+}
+
+func CloneCLIOptsToVPCOpts(opts CloneOptions) vpc.CloneOptions {
+	return vpc.CloneOptions{
+		Name:    opts.Name,
+		NewName: opts.NewName,
+		Region:  opts.Region,
+		RoleARN: opts.RoleARN,
+		CIDR:    opts.CIDR,
+	}
+}
@@ -0,0 +1,93 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// The vpcctl controller reconciles VPC custom resources, see config/crd for the CRD
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+
+	"github.com/bwagner5/vpcctl/pkg/apis/v1alpha1"
+	"github.com/bwagner5/vpcctl/pkg/controller"
+	"github.com/bwagner5/vpcctl/pkg/vpc"
+)
+
+func main() {
+	var metricsAddr, probeAddr string
+	var leaderElection bool
+	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "Address of the metrics endpoint, 0 disables it")
+	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "Address of the health probe endpoint")
+	flag.BoolVar(&leaderElection, "leader-elect", false, "Enable leader election so that only one controller replica is active")
+	zapOpts := zap.Options{}
+	zapOpts.BindFlags(flag.CommandLine)
+	flag.Parse()
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&zapOpts)))
+	logger := ctrl.Log.WithName("vpcctl")
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		fmt.Printf("Error getting AWS config: %s", err)
+		os.Exit(1)
+	}
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+		Scheme:                 scheme,
+		Metrics:                metricsserver.Options{BindAddress: metricsAddr},
+		HealthProbeBindAddress: probeAddr,
+		LeaderElection:         leaderElection,
+		LeaderElectionID:       "vpcctl-controller.vpcctl.io",
+	})
+	if err != nil {
+		fmt.Printf("Error creating the controller manager: %s", err)
+		os.Exit(1)
+	}
+	// Progress events are logged as they happen since creating and deleting a VPC can take several minutes
+	vpcClient := vpc.New(cfg).WithObserver(vpc.ObserverFunc(func(event vpc.Event) {
+		if event.Phase != vpc.PhasePending && event.Phase != vpc.PhaseWaiting {
+			logger.Info(event.String())
+		}
+	}))
+	reconciler := &controller.Reconciler{Kube: mgr.GetClient(), VPCClient: vpcClient}
+	if err := reconciler.SetupWithManager(mgr); err != nil {
+		fmt.Printf("Error setting up the controller: %s", err)
+		os.Exit(1)
+	}
+	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+}
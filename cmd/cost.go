@@ -0,0 +1,124 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/spf13/cobra"
+
+	"github.com/bwagner5/vpcctl/pkg/vpc"
+)
+
+type CostOptions struct {
+	Name       string  `yaml:"name"`
+	ID         string  `yaml:"id"`
+	PriceTable string  `yaml:"priceTable"`
+	FlowLogGB  float64 `yaml:"flowLogGB"`
+	Output     string  `yaml:"output"`
+}
+
+var (
+	costOpts = CostOptions{}
+	cmdCost  = &cobra.Command{
+		Use:   "cost [-f vpc.yaml | --name my-vpc | --id vpc-123]",
+		Short: "Estimate the monthly cost of a VPC",
+		Long: `Estimate the monthly cost of the resources with a fixed price, like NAT gateways and public IPv4 addresses.
+With a config file (-f) the VPC that create would create is estimated, otherwise the existing VPC is estimated.
+Prices come from a price table embedded in vpcctl, pass an updated copy with --price-table.`,
+		Args: cobra.MinimumNArgs(0),
+		Run: func(cmd *cobra.Command, _ []string) {
+			opts, err := ParseConfig(globalOpts, costOpts)
+			if err != nil {
+				fmt.Printf("Error parsing config file (%s): %s", globalOpts.ConfigFile, err)
+			}
+			if globalOpts.Verbose {
+				fmt.Println(PrettyEncode(opts))
+			}
+			cfg, err := config.LoadDefaultConfig(cmd.Context())
+			if err != nil {
+				fmt.Printf("Error getting AWS config: %s", err)
+				os.Exit(1)
+			}
+			priceTable, err := vpc.LoadPriceTable(opts.PriceTable)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			var resources vpc.BillableResources
+			if globalOpts.ConfigFile != "" {
+				createOpts, err := ParseConfig(globalOpts, CreateOptions{CIDR: vpc.DefaultCIDR})
+				if err != nil {
+					fmt.Printf("Error parsing config file (%s): %s", globalOpts.ConfigFile, err)
+					os.Exit(1)
+				}
+				resources = vpc.PlannedBillableResources(CreateCLIOptsToVPCOpts(createOpts))
+			} else {
+				vpcClient := NewVPCClient(cfg)
+				vpcDetails, err := vpcClient.Get(cmd.Context(), vpc.GetOptions{Name: opts.Name, ID: opts.ID})
+				if err != nil {
+					fmt.Println(err)
+					os.Exit(2)
+				}
+				if resources, err = vpcClient.BillableResources(cmd.Context(), vpcDetails); err != nil {
+					fmt.Println(err)
+					os.Exit(2)
+				}
+			}
+			estimate, err := priceTable.Estimate(cfg.Region, resources, opts.FlowLogGB)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			switch opts.Output {
+			case "json":
+				fmt.Println(PrettyEncode(estimate))
+			case "text":
+				printCostEstimate(estimate)
+			default:
+				fmt.Printf("Invalid output %q, must be text or json\n", opts.Output)
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	cmdCost.Flags().StringVarP(&costOpts.Name, "name", "n", "", "Name of the VPC")
+	cmdCost.Flags().StringVar(&costOpts.ID, "id", "", "ID of the VPC")
+	cmdCost.Flags().StringVar(&costOpts.PriceTable, "price-table", "", "YAML price table file to use instead of the embedded one")
+	cmdCost.Flags().Float64Var(&costOpts.FlowLogGB, "flow-log-gb", 0, "GB of flow logs ingested per month to include in the estimate")
+	cmdCost.Flags().StringVarP(&costOpts.Output, "output", "o", "text", "Output format: text or json")
+	rootCmd.AddCommand(cmdCost)
+}
+
+// printCostEstimate prints a line per resource of the estimate, the total, and what the estimate does not include
+func printCostEstimate(estimate *vpc.CostEstimate) {
+	fmt.Printf("Estimated monthly cost in %s (prices updated %s)\n", estimate.Region, estimate.PricesUpdated)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RESOURCE\tCOUNT\tUNIT PRICE\tMONTHLY")
+	for _, item := range estimate.Items {
+		fmt.Fprintf(w, "%s\t%d\t$%g/%s\t$%.2f\n", item.Resource, item.Count, item.UnitPrice, item.Unit, item.Monthly)
+	}
+	fmt.Fprintf(w, "TOTAL\t\t\t$%.2f\n", estimate.Monthly)
+	w.Flush()
+	for _, note := range estimate.Notes {
+		fmt.Printf("* %s\n", note)
+	}
+}
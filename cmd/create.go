@@ -18,35 +18,115 @@ import (
 	"fmt"
 	"math/rand"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/samber/lo"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 
 	"github.com/bwagner5/vpcctl/pkg/vpc"
 )
 
 type CreateOptions struct {
-	Name    string            `yaml:"name"`
-	CIDR    string            `yaml:"cidr"`
-	Subnets []SubnetOptions   `yaml:"subnets"`
-	Tags    map[string]string `yaml:"tags"`
+	Name              string                 `yaml:"name,omitempty"`
+	CIDR              string                 `yaml:"cidr,omitempty"`
+	IPAMPoolID        string                 `yaml:"ipamPoolID,omitempty"`
+	IPAMNetmaskLength int32                  `yaml:"ipamNetmaskLength,omitempty"`
+	Subnets           []SubnetOptions        `yaml:"subnets,omitempty"`
+	Tags              map[string]string      `yaml:"tags,omitempty"`
+	SecurityGroups    []SecurityGroupOptions `yaml:"securityGroups,omitempty"`
+	NetworkACLs       []NetworkACLOptions    `yaml:"networkAcls,omitempty"`
+	// RestrictDefaultSecurityGroup removes all rules from the default security group
+	RestrictDefaultSecurityGroup bool                  `yaml:"restrictDefaultSecurityGroup,omitempty"`
+	TransitGateway               TransitGatewayOptions `yaml:"transitGateway,omitempty"`
+	NAT                          string                `yaml:"nat,omitempty"`
+	NATInstanceType              string                `yaml:"natInstanceType,omitempty"`
+	NATInstanceAMI               string                `yaml:"natInstanceAMI,omitempty"`
+	EnableDNSSupport             *bool                 `yaml:"enableDnsSupport,omitempty"`
+	EnableDNSHostnames           *bool                 `yaml:"enableDnsHostnames,omitempty"`
+	DHCPOptions                  *DHCPOptions          `yaml:"dhcpOptions,omitempty"`
+	PrivateHostedZone            string                `yaml:"privateHostedZone,omitempty"`
+	Resume                       bool                  `yaml:"resume,omitempty"`
+	SkipPreflight                bool                  `yaml:"skipPreflight,omitempty"`
+	Timeout                      time.Duration         `yaml:"timeout,omitempty"`
+	StepTimeout                  time.Duration         `yaml:"stepTimeout,omitempty"`
+}
+
+type DHCPOptions struct {
+	DomainName string   `yaml:"domainName,omitempty"`
+	DNSServers []string `yaml:"dnsServers,omitempty"`
+	NTPServers []string `yaml:"ntpServers,omitempty"`
+}
+
+type TransitGatewayOptions struct {
+	ID               string   `yaml:"id,omitempty"`
+	Tier             string   `yaml:"tier,omitempty"`
+	DedicatedSubnets bool     `yaml:"dedicatedSubnets,omitempty"`
+	Routes           []string `yaml:"routes,omitempty"`
 }
 
 type SubnetOptions struct {
-	AZ     string `yaml:"az"`
-	CIDR   string `yaml:"cidr"`
-	Public bool   `yaml:"public"`
+	AZ     string `yaml:"az,omitempty"`
+	CIDR   string `yaml:"cidr,omitempty"`
+	Public bool   `yaml:"public,omitempty"`
+}
+
+type SecurityGroupOptions struct {
+	Name        string                     `yaml:"name,omitempty"`
+	Description string                     `yaml:"description,omitempty"`
+	Ingress     []SecurityGroupRuleOptions `yaml:"ingress,omitempty"`
+	Egress      []SecurityGroupRuleOptions `yaml:"egress,omitempty"`
+}
+
+type SecurityGroupRuleOptions struct {
+	Protocol      string `yaml:"protocol,omitempty"`
+	FromPort      int32  `yaml:"fromPort,omitempty"`
+	ToPort        int32  `yaml:"toPort,omitempty"`
+	CIDR          string `yaml:"cidr,omitempty"`
+	PrefixListID  string `yaml:"prefixListID,omitempty"`
+	Tier          string `yaml:"tier,omitempty"`
+	SecurityGroup string `yaml:"securityGroup,omitempty"`
+	Description   string `yaml:"description,omitempty"`
+}
+
+type NetworkACLOptions struct {
+	Name    string                  `yaml:"name,omitempty"`
+	Tier    string                  `yaml:"tier,omitempty"`
+	Ingress []NetworkACLRuleOptions `yaml:"ingress,omitempty"`
+	Egress  []NetworkACLRuleOptions `yaml:"egress,omitempty"`
+}
+
+type NetworkACLRuleOptions struct {
+	RuleNumber int32  `yaml:"ruleNumber,omitempty"`
+	Protocol   string `yaml:"protocol,omitempty"`
+	FromPort   int32  `yaml:"fromPort,omitempty"`
+	ToPort     int32  `yaml:"toPort,omitempty"`
+	CIDR       string `yaml:"cidr,omitempty"`
+	Tier       string `yaml:"tier,omitempty"`
+	Action     string `yaml:"action,omitempty"`
 }
 
 var (
-	createOpts = CreateOptions{}
-	cmdCreate  = &cobra.Command{
+	createOpts         = CreateOptions{}
+	enableDNSSupport   bool
+	enableDNSHostnames bool
+	createDryRun       bool
+	cmdCreate          = &cobra.Command{
 		Use:   "create [--name my-vpc]",
 		Short: "Create a VPC",
 		Long:  `Create a VPC with subresources like subnets, route-tables, etc. to get going quickly`,
 		Args:  cobra.MinimumNArgs(0),
 		Run: func(cmd *cobra.Command, _ []string) {
+			// only set the DNS attributes when the flags are passed so that they can be overridden by the config file
+			if cmd.Flags().Changed("enable-dns-support") {
+				createOpts.EnableDNSSupport = &enableDNSSupport
+			}
+			if cmd.Flags().Changed("enable-dns-hostnames") {
+				createOpts.EnableDNSHostnames = &enableDNSHostnames
+			}
 			opts, err := ParseConfig(globalOpts, createOpts)
 			if err != nil {
 				fmt.Printf("Error parsing config file (%s): %s", globalOpts.ConfigFile, err)
@@ -59,12 +139,31 @@ var (
 				fmt.Printf("Error getting AWS config: %s", err)
 				os.Exit(1)
 			}
+			if createDryRun {
+				if err := printDryRun(cfg.Region, opts); err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+				return
+			}
 
-			vpcClient := vpc.New(cfg)
-			vpcDetails, err := vpcClient.Create(cmd.Context(), CreateCLIOptsToVPCOpts(opts))
+			// Ctrl-C stops the create between AWS calls so that the resources created so far are returned
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			vpcClient := NewVPCClient(cfg)
+			vpcOpts := CreateCLIOptsToVPCOpts(opts)
+			vpcDetails, err := WithProgress(vpcClient, func(vpcClient *vpc.Client) (*vpc.Details, error) {
+				return Journaled(ctx, vpcClient, vpcOpts.Name, vpc.OperationCreate, vpcOpts, func(vpcClient *vpc.Client) (*vpc.Details, error) {
+					return vpcClient.Create(ctx, vpcOpts)
+				})
+			})
 			if err != nil {
 				fmt.Println(PrettyEncode(vpcDetails))
 				fmt.Println(err)
+				if vpcDetails != nil && vpcDetails.VPC != nil {
+					fmt.Printf("Run create again with --resume to finish VPC %s or delete it with vpcctl delete --id %s\n", opts.Name, *vpcDetails.VPC.VpcId)
+				}
+				stop()
 				os.Exit(2)
 			}
 			fmt.Println(PrettyEncode(vpcDetails))
@@ -75,16 +174,84 @@ var (
 func init() {
 	//nolint:gosec // we don't need to use crypto/rand here for a default name
 	cmdCreate.Flags().StringVarP(&createOpts.Name, "name", "n", fmt.Sprintf("vpcctl-generated-%d", rand.Int()), "Name of the VPC")
-	cmdCreate.Flags().StringVarP(&createOpts.CIDR, "cidr", "c", "10.0.0.0/16", "CIDR of the VPC")
+	cmdCreate.Flags().StringVarP(&createOpts.CIDR, "cidr", "c", vpc.DefaultCIDR, "CIDR of the VPC")
+	cmdCreate.Flags().StringVar(&createOpts.IPAMPoolID, "ipam-pool-id", "", "IPAM pool to allocate the VPC CIDR from (overrides --cidr)")
+	cmdCreate.Flags().Int32Var(&createOpts.IPAMNetmaskLength, "ipam-netmask-length", 0, "Netmask length of the CIDR allocated from the IPAM pool (defaults to the pool's default)")
+	cmdCreate.Flags().BoolVar(&createOpts.RestrictDefaultSecurityGroup, "restrict-default-security-group", false, "Remove all rules from the VPC's default security group")
+	cmdCreate.Flags().StringVar(&createOpts.TransitGateway.ID, "transit-gateway-id", "", "Transit Gateway to attach the VPC to")
+	cmdCreate.Flags().StringSliceVar(&createOpts.TransitGateway.Routes, "transit-gateway-routes", nil, "Destination CIDRs to route to the Transit Gateway")
+	cmdCreate.Flags().StringVar(&createOpts.NAT, "nat", vpc.NATModeGateway, fmt.Sprintf("NAT mode for private subnets (%s, %s or %s)", vpc.NATModeGateway, vpc.NATModeInstance, vpc.NATModeNone))
+	cmdCreate.Flags().StringVar(&createOpts.NATInstanceType, "nat-instance-type", "", "Instance type of the NAT instance (defaults to t4g.nano)")
+	cmdCreate.Flags().StringVar(&createOpts.NATInstanceAMI, "nat-instance-ami", "", "AMI of the NAT instance (defaults to the latest Amazon Linux 2023 AMI)")
+	cmdCreate.Flags().BoolVar(&enableDNSSupport, "enable-dns-support", true, "Enable the Amazon provided DNS server in the VPC")
+	cmdCreate.Flags().BoolVar(&createOpts.Resume, "resume", false, "Keep the resources of an existing VPC with the name and only create the missing ones")
+	cmdCreate.Flags().BoolVar(&createOpts.SkipPreflight, "skip-preflight", false, "Do not check the quotas and the CIDR overlap with the region's VPCs before creating any resources")
+	cmdCreate.Flags().DurationVar(&createOpts.Timeout, "timeout", 0, "Cancel the create when it takes longer (no timeout when 0)")
+	cmdCreate.Flags().DurationVar(&createOpts.StepTimeout, "step-timeout", vpc.DefaultStepTimeout, "How long each step waits for its resources, e.g. the NAT gateway to become available")
+	cmdCreate.Flags().BoolVar(&enableDNSHostnames, "enable-dns-hostnames", true, "Assign public DNS hostnames to instances in the VPC")
+	cmdCreate.Flags().StringVar(&createOpts.PrivateHostedZone, "private-hosted-zone", "", "Domain name of a Route 53 private hosted zone to associate with the VPC")
+	cmdCreate.Flags().BoolVar(&createDryRun, "dry-run", false, "Print the VPC that would be created and its estimated monthly cost without creating it")
 	cmdCreate.Flags().StringToStringVarP(&createOpts.Tags, "tags", "t", nil, "Additional tags to add to VPC resources")
 	rootCmd.AddCommand(cmdCreate)
 }
 
+// printDryRun prints the config of the VPC that create would create and its estimated monthly cost
+func printDryRun(region string, opts CreateOptions) error {
+	vpcOpts := CreateCLIOptsToVPCOpts(opts)
+	// the default subnets of a CIDR allocated from an IPAM pool are only known once the VPC is created
+	if len(vpcOpts.Subnets) == 0 && vpcOpts.IPAMPoolID == "" {
+		subnets, err := vpc.DefaultSubnets(region, vpcOpts.CIDR)
+		if err != nil {
+			return err
+		}
+		vpcOpts.Subnets = subnets
+	}
+	configBytes, err := yaml.Marshal(VPCOptsToCreateCLIOpts(vpcOpts))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Dry run, no resources were created. VPC %s would be created with:\n%s\n", vpcOpts.Name, configBytes)
+	priceTable, err := vpc.LoadPriceTable("")
+	if err != nil {
+		return err
+	}
+	estimate, err := priceTable.Estimate(region, vpc.PlannedBillableResources(vpcOpts), 0)
+	if err != nil {
+		fmt.Printf("No cost estimate: %s\n", err)
+		return nil
+	}
+	printCostEstimate(estimate)
+	return nil
+}
+
+func formatConfig(details *vpc.Details) ([]byte, error) {
+	return yaml.Marshal(VPCOptsToCreateCLIOpts(details.ToCreateOptions()))
+}
+
 func CreateCLIOptsToVPCOpts(opts CreateOptions) vpc.CreateOptions {
+	var transitGateway *vpc.CreateTransitGatewayAttachmentOptions
+	if opts.TransitGateway.ID != "" {
+		transitGateway = &vpc.CreateTransitGatewayAttachmentOptions{
+			TransitGatewayID: opts.TransitGateway.ID,
+			Tier:             opts.TransitGateway.Tier,
+			DedicatedSubnets: opts.TransitGateway.DedicatedSubnets,
+			Routes:           opts.TransitGateway.Routes,
+		}
+	}
+	var dhcpOptions *vpc.CreateDHCPOptions
+	if opts.DHCPOptions != nil {
+		dhcpOptions = &vpc.CreateDHCPOptions{
+			DomainName: opts.DHCPOptions.DomainName,
+			DNSServers: opts.DHCPOptions.DNSServers,
+			NTPServers: opts.DHCPOptions.NTPServers,
+		}
+	}
 	return vpc.CreateOptions{
-		Name: opts.Name,
-		CIDR: opts.CIDR,
-		Tags: opts.Tags,
+		Name:              opts.Name,
+		CIDR:              opts.CIDR,
+		IPAMPoolID:        opts.IPAMPoolID,
+		IPAMNetmaskLength: opts.IPAMNetmaskLength,
+		Tags:              opts.Tags,
 		Subnets: lo.Map(opts.Subnets, func(snOpts SubnetOptions, _ int) vpc.CreateSubnetOptions {
 			return vpc.CreateSubnetOptions{
 				AZ:     snOpts.AZ,
@@ -92,5 +259,144 @@ func CreateCLIOptsToVPCOpts(opts CreateOptions) vpc.CreateOptions {
 				Public: snOpts.Public,
 			}
 		}),
+		SecurityGroups: lo.Map(opts.SecurityGroups, func(sgOpts SecurityGroupOptions, _ int) vpc.CreateSecurityGroupOptions {
+			return vpc.CreateSecurityGroupOptions{
+				Name:        sgOpts.Name,
+				Description: sgOpts.Description,
+				Ingress:     lo.Map(sgOpts.Ingress, securityGroupRuleCLIOptsToVPCOpts),
+				Egress:      lo.Map(sgOpts.Egress, securityGroupRuleCLIOptsToVPCOpts),
+			}
+		}),
+		NetworkACLs: lo.Map(opts.NetworkACLs, func(aclOpts NetworkACLOptions, _ int) vpc.CreateNetworkACLOptions {
+			return vpc.CreateNetworkACLOptions{
+				Name:    aclOpts.Name,
+				Tier:    aclOpts.Tier,
+				Ingress: lo.Map(aclOpts.Ingress, networkACLRuleCLIOptsToVPCOpts),
+				Egress:  lo.Map(aclOpts.Egress, networkACLRuleCLIOptsToVPCOpts),
+			}
+		}),
+		RestrictDefaultSecurityGroup: opts.RestrictDefaultSecurityGroup,
+		TransitGateway:               transitGateway,
+		NAT:                          opts.NAT,
+		NATInstanceType:              opts.NATInstanceType,
+		NATInstanceAMI:               opts.NATInstanceAMI,
+		EnableDNSSupport:             opts.EnableDNSSupport,
+		EnableDNSHostnames:           opts.EnableDNSHostnames,
+		DHCPOptions:                  dhcpOptions,
+		Resume:                       opts.Resume,
+		SkipPreflight:                opts.SkipPreflight,
+		Timeout:                      opts.Timeout,
+		StepTimeout:                  opts.StepTimeout,
+		PrivateHostedZone:            opts.PrivateHostedZone,
+	}
+}
+
+// VPCOptsToCreateCLIOpts is the inverse of CreateCLIOptsToVPCOpts
+func VPCOptsToCreateCLIOpts(opts vpc.CreateOptions) CreateOptions {
+	var transitGateway TransitGatewayOptions
+	if opts.TransitGateway != nil {
+		transitGateway = TransitGatewayOptions{
+			ID:               opts.TransitGateway.TransitGatewayID,
+			Tier:             opts.TransitGateway.Tier,
+			DedicatedSubnets: opts.TransitGateway.DedicatedSubnets,
+			Routes:           opts.TransitGateway.Routes,
+		}
+	}
+	var dhcpOptions *DHCPOptions
+	if opts.DHCPOptions != nil {
+		dhcpOptions = &DHCPOptions{
+			DomainName: opts.DHCPOptions.DomainName,
+			DNSServers: opts.DHCPOptions.DNSServers,
+			NTPServers: opts.DHCPOptions.NTPServers,
+		}
+	}
+	return CreateOptions{
+		Name:              opts.Name,
+		CIDR:              opts.CIDR,
+		IPAMPoolID:        opts.IPAMPoolID,
+		IPAMNetmaskLength: opts.IPAMNetmaskLength,
+		Tags:              opts.Tags,
+		Subnets: lo.Map(opts.Subnets, func(snOpts vpc.CreateSubnetOptions, _ int) SubnetOptions {
+			return SubnetOptions{
+				AZ:     snOpts.AZ,
+				CIDR:   snOpts.CIDR,
+				Public: snOpts.Public,
+			}
+		}),
+		SecurityGroups: lo.Map(opts.SecurityGroups, func(sgOpts vpc.CreateSecurityGroupOptions, _ int) SecurityGroupOptions {
+			return SecurityGroupOptions{
+				Name:        sgOpts.Name,
+				Description: sgOpts.Description,
+				Ingress:     lo.Map(sgOpts.Ingress, securityGroupRuleVPCOptsToCLIOpts),
+				Egress:      lo.Map(sgOpts.Egress, securityGroupRuleVPCOptsToCLIOpts),
+			}
+		}),
+		NetworkACLs: lo.Map(opts.NetworkACLs, func(aclOpts vpc.CreateNetworkACLOptions, _ int) NetworkACLOptions {
+			return NetworkACLOptions{
+				Name:    aclOpts.Name,
+				Tier:    aclOpts.Tier,
+				Ingress: lo.Map(aclOpts.Ingress, networkACLRuleVPCOptsToCLIOpts),
+				Egress:  lo.Map(aclOpts.Egress, networkACLRuleVPCOptsToCLIOpts),
+			}
+		}),
+		RestrictDefaultSecurityGroup: opts.RestrictDefaultSecurityGroup,
+		TransitGateway:               transitGateway,
+		NAT:                          opts.NAT,
+		NATInstanceType:              opts.NATInstanceType,
+		NATInstanceAMI:               opts.NATInstanceAMI,
+		EnableDNSSupport:             opts.EnableDNSSupport,
+		EnableDNSHostnames:           opts.EnableDNSHostnames,
+		DHCPOptions:                  dhcpOptions,
+		PrivateHostedZone:            opts.PrivateHostedZone,
+	}
+}
+
+func securityGroupRuleCLIOptsToVPCOpts(rule SecurityGroupRuleOptions, _ int) vpc.SecurityGroupRuleOptions {
+	return vpc.SecurityGroupRuleOptions{
+		Protocol:      rule.Protocol,
+		FromPort:      rule.FromPort,
+		ToPort:        rule.ToPort,
+		CIDR:          rule.CIDR,
+		PrefixListID:  rule.PrefixListID,
+		Tier:          rule.Tier,
+		SecurityGroup: rule.SecurityGroup,
+		Description:   rule.Description,
+	}
+}
+
+func networkACLRuleCLIOptsToVPCOpts(rule NetworkACLRuleOptions, _ int) vpc.NetworkACLRuleOptions {
+	return vpc.NetworkACLRuleOptions{
+		RuleNumber: rule.RuleNumber,
+		Protocol:   rule.Protocol,
+		FromPort:   rule.FromPort,
+		ToPort:     rule.ToPort,
+		CIDR:       rule.CIDR,
+		Tier:       rule.Tier,
+		Action:     rule.Action,
+	}
+}
+
+func securityGroupRuleVPCOptsToCLIOpts(rule vpc.SecurityGroupRuleOptions, _ int) SecurityGroupRuleOptions {
+	return SecurityGroupRuleOptions{
+		Protocol:      rule.Protocol,
+		FromPort:      rule.FromPort,
+		ToPort:        rule.ToPort,
+		CIDR:          rule.CIDR,
+		PrefixListID:  rule.PrefixListID,
+		Tier:          rule.Tier,
+		SecurityGroup: rule.SecurityGroup,
+		Description:   rule.Description,
+	}
+}
+
+func networkACLRuleVPCOptsToCLIOpts(rule vpc.NetworkACLRuleOptions, _ int) NetworkACLRuleOptions {
+	return NetworkACLRuleOptions{
+		RuleNumber: rule.RuleNumber,
+		Protocol:   rule.Protocol,
+		FromPort:   rule.FromPort,
+		ToPort:     rule.ToPort,
+		CIDR:       rule.CIDR,
+		Tier:       rule.Tier,
+		Action:     rule.Action,
 	}
 }
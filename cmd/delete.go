@@ -15,26 +15,39 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 
 	"github.com/bwagner5/vpcctl/pkg/vpc"
 )
 
 type DeleteOptions struct {
-	Name string `yaml:"name"`
+	Name        string            `yaml:"name"`
+	ID          string            `yaml:"id"`
+	Selector    map[string]string `yaml:"selector"`
+	Timeout     time.Duration     `yaml:"timeout"`
+	StepTimeout time.Duration     `yaml:"stepTimeout"`
+	FromJournal bool              `yaml:"fromJournal"`
+	Yes         bool              `yaml:"yes"`
 }
 
 var (
 	deleteOpts = DeleteOptions{}
 	cmdDelete  = &cobra.Command{
-		Use:   "delete [--name my-vpc]",
+		Use:   "delete [--name my-vpc | --id vpc-123 | -l key=value] [--name 'ci-*' --yes]",
 		Short: "Delete a VPC",
-		Long:  `Delete a VPC with subresources like subnets, route-tables, etc.`,
-		Args:  cobra.MinimumNArgs(0),
+		Long: `Delete a VPC with subresources like subnets, route-tables, etc.
+When the name is a glob (e.g. --name 'ci-*'), all matching VPCs are deleted once confirmed with --yes.`,
+		Args: cobra.MinimumNArgs(0),
 		Run: func(cmd *cobra.Command, _ []string) {
 			opts, err := ParseConfig(globalOpts, deleteOpts)
 			if err != nil {
@@ -49,19 +62,83 @@ var (
 				os.Exit(1)
 			}
 
-			vpcClient := vpc.New(cfg)
-			vpcDetails, err := vpcClient.Delete(cmd.Context(), vpc.DeleteOptions{Name: opts.Name})
-			if err != nil {
-				fmt.Println(PrettyEncode(vpcDetails))
-				fmt.Println(err)
-				os.Exit(2)
+			// Ctrl-C stops the delete between AWS calls, running it again deletes the remaining resources
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			vpcClient := NewVPCClient(cfg)
+			toDelete := []vpc.DeleteOptions{{Name: opts.Name, ID: opts.ID, Tags: opts.Selector}}
+			if vpc.IsGlob(opts.Name) {
+				vpcs, err := vpcClient.Find(ctx, vpc.ListOptions{Name: opts.Name, Tags: opts.Selector})
+				if err != nil {
+					fmt.Println(err)
+					os.Exit(2)
+				}
+				if len(vpcs) == 0 {
+					fmt.Printf("No VPCs match %s\n", opts.Name)
+				}
+				toDelete = lo.Map(vpcs, func(v types.Vpc, _ int) vpc.DeleteOptions {
+					name, _ := lo.Find(v.Tags, func(tag types.Tag) bool { return *tag.Key == "Name" })
+					return vpc.DeleteOptions{Name: lo.FromPtr(name.Value), ID: *v.VpcId}
+				})
+				if len(toDelete) != 0 && !opts.Yes {
+					fmt.Printf("%s matches %d VPCs:\n", opts.Name, len(toDelete))
+					for _, deleteOpt := range toDelete {
+						fmt.Printf("  %s (%s)\n", deleteOpt.ID, deleteOpt.Name)
+					}
+					fmt.Println("Run again with --yes to delete them")
+					os.Exit(1)
+				}
+			}
+			if opts.FromJournal {
+				resourceIDs, err := journalResourceIDs(ctx, vpcClient, opts.Name)
+				if err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+				toDelete[0].ResourceIDs = resourceIDs
+			}
+			for _, deleteOpt := range toDelete {
+				deleteOpt.Timeout, deleteOpt.StepTimeout = opts.Timeout, opts.StepTimeout
+				vpcDetails, err := WithProgress(vpcClient, func(vpcClient *vpc.Client) (*vpc.Details, error) {
+					return Journaled(ctx, vpcClient, deleteOpt.Name, vpc.OperationDelete, deleteOpt, func(vpcClient *vpc.Client) (*vpc.Details, error) {
+						return vpcClient.Delete(ctx, deleteOpt)
+					})
+				})
+				if err != nil {
+					fmt.Println(PrettyEncode(vpcDetails))
+					fmt.Println(err)
+					stop()
+					os.Exit(2)
+				}
+				fmt.Printf("Deleted VPC %s\n", *vpcDetails.VPC.VpcId)
 			}
-			fmt.Printf("Deleted VPC %s", opts.Name)
 		},
 	}
 )
 
 func init() {
-	cmdDelete.Flags().StringVarP(&deleteOpts.Name, "name", "n", "", "Name of the VPC")
+	cmdDelete.Flags().StringVarP(&deleteOpts.Name, "name", "n", "", "Name or name glob of the VPC")
+	cmdDelete.Flags().StringVar(&deleteOpts.ID, "id", "", "ID of the VPC")
+	cmdDelete.Flags().StringToStringVarP(&deleteOpts.Selector, "selector", "l", nil, "Tag selector of the VPC, e.g. env=ci,team=infra")
+	cmdDelete.Flags().DurationVar(&deleteOpts.Timeout, "timeout", 0, "Cancel each VPC's delete when it takes longer (no timeout when 0)")
+	cmdDelete.Flags().DurationVar(&deleteOpts.StepTimeout, "step-timeout", vpc.DefaultStepTimeout, "How long each step waits for its resources, e.g. the NAT gateways to be deleted")
+	cmdDelete.Flags().BoolVarP(&deleteOpts.Yes, "yes", "y", false, "Confirm deleting all VPCs that match a name glob")
+	cmdDelete.Flags().BoolVar(&deleteOpts.FromJournal, "from-journal", false, "Delete the resources recorded in the VPC's journal by ID, which works even when their tags were lost")
 	rootCmd.AddCommand(cmdDelete)
 }
+
+// journalResourceIDs returns the IDs of the resources that the journal of the VPC named name records as not deleted
+func journalResourceIDs(ctx context.Context, vpcClient *vpc.Client, name string) ([]string, error) {
+	if name == "" || vpc.IsGlob(name) {
+		return nil, fmt.Errorf("a VPC name is required to delete from a journal")
+	}
+	journal, err := vpcClient.OpenJournal(ctx, StateDir(), name)
+	if err != nil {
+		return nil, err
+	}
+	resourceIDs := journal.ResourceIDs()
+	if len(resourceIDs) == 0 {
+		return nil, fmt.Errorf("the journal of VPC %s in %s has no resources to delete", name, StateDir())
+	}
+	return resourceIDs, nil
+}
@@ -0,0 +1,86 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/spf13/cobra"
+
+	"github.com/bwagner5/vpcctl/pkg/vpc"
+)
+
+type ExportOptions struct {
+	Name     string            `yaml:"name"`
+	ID       string            `yaml:"id"`
+	Selector map[string]string `yaml:"selector"`
+	Output   string            `yaml:"output"`
+}
+
+var (
+	exportOpts = ExportOptions{}
+	cmdExport  = &cobra.Command{
+		Use:   "export [--name my-vpc | --id vpc-123 | -l key=value] [-o cloudformation]",
+		Short: "Export a VPC as an IaC template",
+		Long: `Export a VPC as an infrastructure as code template, so that a VPC created with vpcctl can be managed by an IaC pipeline.
+CloudFormation templates can also be used in CDK apps with CfnInclude.`,
+		Args: cobra.MinimumNArgs(0),
+		Run: func(cmd *cobra.Command, _ []string) {
+			opts, err := ParseConfig(globalOpts, exportOpts)
+			if err != nil {
+				fmt.Printf("Error parsing config file (%s): %s", globalOpts.ConfigFile, err)
+			}
+			if globalOpts.Verbose {
+				fmt.Println(PrettyEncode(opts))
+			}
+			cfg, err := config.LoadDefaultConfig(cmd.Context())
+			if err != nil {
+				fmt.Printf("Error getting AWS config: %s", err)
+				os.Exit(1)
+			}
+
+			formatter, err := vpc.GetFormatter(opts.Output)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			vpcClient := NewVPCClient(cfg)
+			vpcDetails, err := vpcClient.Get(cmd.Context(), vpc.GetOptions{Name: opts.Name, ID: opts.ID, Tags: opts.Selector})
+			if err != nil {
+				fmt.Println(PrettyEncode(vpcDetails))
+				fmt.Println(err)
+				os.Exit(2)
+			}
+			out, err := formatter.Format(vpcDetails)
+			if err != nil {
+				fmt.Printf("Error exporting VPC as %s: %s\n", formatter.Name(), err)
+				os.Exit(2)
+			}
+			fmt.Println(strings.TrimSuffix(string(out), "\n"))
+		},
+	}
+)
+
+func init() {
+	cmdExport.Flags().StringVarP(&exportOpts.Name, "name", "n", "", "Name of the VPC")
+	cmdExport.Flags().StringVar(&exportOpts.ID, "id", "", "ID of the VPC")
+	cmdExport.Flags().StringToStringVarP(&exportOpts.Selector, "selector", "l", nil, "Tag selector of the VPC, e.g. env=ci,team=infra")
+	cmdExport.Flags().StringVarP(&exportOpts.Output, "output", "o", "cloudformation", "Template format: cloudformation, cloudformation-json, terraform-import")
+	rootCmd.AddCommand(cmdExport)
+}
@@ -17,6 +17,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/samber/lo"
@@ -25,20 +26,17 @@ import (
 	"github.com/bwagner5/vpcctl/pkg/vpc"
 )
 
-const (
-	OutputJSON   = "json"
-	OutputEKSCTL = "eksctl"
-)
-
 type GetOptions struct {
-	Name   string `yaml:"name"`
-	Output string `yaml:"output"`
+	Name     string            `yaml:"name"`
+	ID       string            `yaml:"id"`
+	Selector map[string]string `yaml:"selector"`
+	Output   string            `yaml:"output"`
 }
 
 var (
 	getOpts = GetOptions{}
 	cmdGet  = &cobra.Command{
-		Use:   "get [--name my-vpc]",
+		Use:   "get [--name my-vpc | --id vpc-123 | -l key=value]",
 		Short: "Get a VPC",
 		Long:  `Get a VPC with subresources like subnets, route-tables, etc.`,
 		Args:  cobra.MinimumNArgs(0),
@@ -56,25 +54,47 @@ var (
 				os.Exit(1)
 			}
 
-			vpcClient := vpc.New(cfg)
-			vpcDetails, err := vpcClient.Get(cmd.Context(), vpc.GetOptions{Name: opts.Name})
+			formatter, err := vpc.GetFormatter(opts.Output)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			vpcClient := NewVPCClient(cfg)
+			vpcDetails, err := vpcClient.Get(cmd.Context(), vpc.GetOptions{Name: opts.Name, ID: opts.ID, Tags: opts.Selector})
 			if err != nil {
 				fmt.Println(PrettyEncode(vpcDetails))
 				fmt.Println(err)
 				os.Exit(2)
 			}
-			switch opts.Output {
-			case OutputEKSCTL:
-				fmt.Println(lo.Must(vpcDetails.OutputEKSCTL()))
-			case OutputJSON:
-				fmt.Println(PrettyEncode(vpcDetails))
+			out, err := formatter.Format(vpcDetails)
+			if err != nil {
+				fmt.Printf("Error formatting output as %s: %s\n", formatter.Name(), err)
+				os.Exit(2)
 			}
+			fmt.Println(strings.TrimSuffix(string(out), "\n"))
 		},
 	}
 )
 
 func init() {
+	// registered here rather than in pkg/vpc since the config file format is defined by the CLI,
+	// and before the help below is built from the formatters
+	vpc.RegisterFormatter(vpc.FormatterFunc{
+		FormatterName:        "config",
+		FormatterDescription: "vpcctl config file to create a VPC with the same layout",
+		FormatFunc:           formatConfig,
+	})
+	cmdGet.Long += "\n\nOutput formats:\n"
+	for _, formatter := range vpc.Formatters() {
+		cmdGet.Long += fmt.Sprintf("  %-18s %s\n", formatter.Name(), formatter.Description())
+	}
+	cmdGet.Long += fmt.Sprintf("  %-18s %s\n", vpc.FormatterTemplatePrefix+"...", "Go text/template, e.g. template={{.VPC.VpcId}}")
+	cmdGet.Long += fmt.Sprintf("  %-18s %s\n", vpc.FormatterJSONPathPrefix+"...", "JSONPath expression, e.g. jsonpath={.Subnets[*].SubnetId}")
 	cmdGet.Flags().StringVarP(&getOpts.Name, "name", "n", "", "Name of the VPC")
-	cmdGet.Flags().StringVarP(&getOpts.Output, "output", "o", OutputJSON, "Output format")
+	cmdGet.Flags().StringVar(&getOpts.ID, "id", "", "ID of the VPC")
+	cmdGet.Flags().StringToStringVarP(&getOpts.Selector, "selector", "l", nil, "Tag selector of the VPC, e.g. env=ci,team=infra")
+	cmdGet.Flags().StringVarP(&getOpts.Output, "output", "o", "json", fmt.Sprintf("Output format: %s, %s<template> or %s<jsonpath>",
+		strings.Join(lo.Map(vpc.Formatters(), func(f vpc.Formatter, _ int) string { return f.Name() }), ", "), vpc.FormatterTemplatePrefix, vpc.FormatterJSONPathPrefix))
 	rootCmd.AddCommand(cmdGet)
 }
@@ -0,0 +1,97 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+
+	"github.com/bwagner5/vpcctl/pkg/vpc"
+)
+
+type HistoryOptions struct {
+	Name   string `yaml:"name"`
+	Output string `yaml:"output"`
+}
+
+var (
+	historyOpts = HistoryOptions{}
+	cmdHistory  = &cobra.Command{
+		Use:   "history [--name 'my-*'] [-o json]",
+		Short: "Show past operations",
+		Long:  `Show the create and delete operations recorded in the journals of the state directory (see --journal)`,
+		Args:  cobra.MinimumNArgs(0),
+		Run: func(_ *cobra.Command, _ []string) {
+			opts, err := ParseConfig(globalOpts, historyOpts)
+			if err != nil {
+				fmt.Printf("Error parsing config file (%s): %s", globalOpts.ConfigFile, err)
+			}
+			journals, err := vpc.ListJournals(StateDir())
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(2)
+			}
+			journals = lo.Filter(journals, func(journal *vpc.Journal, _ int) bool {
+				matched, err := path.Match(lo.CoalesceOrEmpty(opts.Name, "*"), journal.Name)
+				return err == nil && matched
+			})
+			switch opts.Output {
+			case "json":
+				fmt.Println(PrettyEncode(journals))
+			case "text":
+				printHistory(journals)
+			default:
+				fmt.Printf("Invalid output %q, must be text or json\n", opts.Output)
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+func init() {
+	cmdHistory.Flags().StringVarP(&historyOpts.Name, "name", "n", "", "Name glob of the VPCs, e.g. 'ci-*'")
+	cmdHistory.Flags().StringVarP(&historyOpts.Output, "output", "o", "text", "Output format: text or json")
+	rootCmd.AddCommand(cmdHistory)
+}
+
+// printHistory prints a line per operation of the journals, oldest first
+func printHistory(journals []*vpc.Journal) {
+	type historyEntry struct {
+		journal   *vpc.Journal
+		operation *vpc.JournalOperation
+	}
+	entries := lo.FlatMap(journals, func(journal *vpc.Journal, _ int) []historyEntry {
+		return lo.Map(journal.Operations, func(operation *vpc.JournalOperation, _ int) historyEntry { return historyEntry{journal, operation} })
+	})
+	sort.Slice(entries, func(i, j int) bool { return entries[i].operation.Started.Before(entries[j].operation.Started) })
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STARTED\tNAME\tACCOUNT\tREGION\tOPERATION\tOUTCOME\tDURATION\tERROR")
+	for _, entry := range entries {
+		duration := "-"
+		if entry.operation.Finished != nil {
+			duration = entry.operation.Finished.Sub(entry.operation.Started).Round(time.Second).String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", entry.operation.Started.Format(time.RFC3339), entry.journal.Name, entry.journal.Account,
+			entry.journal.Region, entry.operation.Operation, entry.operation.Outcome, duration, entry.operation.Error)
+	}
+	w.Flush()
+}
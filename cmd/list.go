@@ -24,21 +24,31 @@ import (
 	"github.com/bwagner5/vpcctl/pkg/vpc"
 )
 
+type ListOptions struct {
+	Name     string            `yaml:"name"`
+	Selector map[string]string `yaml:"selector"`
+}
+
 var (
-	cmdList = &cobra.Command{
-		Use:   "list ",
+	listOpts = ListOptions{}
+	cmdList  = &cobra.Command{
+		Use:   "list [--name 'my-*'] [-l key=value]",
 		Short: "List VPCs",
 		Long:  `List VPCs created with vpcctl`,
 		Args:  cobra.MinimumNArgs(0),
 		Run: func(cmd *cobra.Command, _ []string) {
+			opts, err := ParseConfig(globalOpts, listOpts)
+			if err != nil {
+				fmt.Printf("Error parsing config file (%s): %s", globalOpts.ConfigFile, err)
+			}
 			cfg, err := config.LoadDefaultConfig(cmd.Context())
 			if err != nil {
 				fmt.Printf("Error getting AWS config: %s", err)
 				os.Exit(1)
 			}
 
-			vpcClient := vpc.New(cfg)
-			vpcs, err := vpcClient.List(cmd.Context())
+			vpcClient := NewVPCClient(cfg)
+			vpcs, err := vpcClient.List(cmd.Context(), vpc.ListOptions{Name: opts.Name, Tags: opts.Selector})
 			if err != nil {
 				fmt.Println(err)
 				os.Exit(2)
@@ -51,5 +61,7 @@ var (
 )
 
 func init() {
+	cmdList.Flags().StringVarP(&listOpts.Name, "name", "n", "", "Name glob of the VPCs, e.g. 'ci-*'")
+	cmdList.Flags().StringToStringVarP(&listOpts.Selector, "selector", "l", nil, "Tag selector of the VPCs, e.g. env=ci,team=infra")
 	rootCmd.AddCommand(cmdList)
 }
@@ -0,0 +1,101 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/spf13/cobra"
+
+	"github.com/bwagner5/vpcctl/pkg/vpc"
+)
+
+type PeerOptions struct {
+	From      string `yaml:"from"`
+	To        string `yaml:"to"`
+	ToRegion  string `yaml:"toRegion"`
+	ToRoleARN string `yaml:"toRoleARN"`
+}
+
+var (
+	peerOpts = PeerOptions{}
+	cmdPeer  = &cobra.Command{
+		Use:   "peer --from my-vpc --to my-other-vpc",
+		Short: "Peer two VPCs",
+		Long:  `Peer two VPCs created with vpcctl and route traffic between them`,
+		Args:  cobra.MinimumNArgs(0),
+		Run: func(cmd *cobra.Command, _ []string) {
+			peer(cmd, func(vpcClient *vpc.Client, opts PeerOptions) (*vpc.PeeringDetails, error) {
+				return vpcClient.Peer(cmd.Context(), PeerCLIOptsToVPCOpts(opts))
+			})
+		},
+	}
+	cmdUnpeer = &cobra.Command{
+		Use:   "unpeer --from my-vpc --to my-other-vpc",
+		Short: "Unpeer two VPCs",
+		Long:  `Remove the peering connection and routes between two VPCs created with vpcctl`,
+		Args:  cobra.MinimumNArgs(0),
+		Run: func(cmd *cobra.Command, _ []string) {
+			peer(cmd, func(vpcClient *vpc.Client, opts PeerOptions) (*vpc.PeeringDetails, error) {
+				return vpcClient.Unpeer(cmd.Context(), PeerCLIOptsToVPCOpts(opts))
+			})
+		},
+	}
+)
+
+func init() {
+	for _, cmd := range []*cobra.Command{cmdPeer, cmdUnpeer} {
+		cmd.Flags().StringVar(&peerOpts.From, "from", "", "Name of the requester VPC")
+		cmd.Flags().StringVar(&peerOpts.To, "to", "", "Name of the accepter VPC")
+		cmd.Flags().StringVar(&peerOpts.ToRegion, "to-region", "", "Region of the accepter VPC (defaults to the current region)")
+		cmd.Flags().StringVar(&peerOpts.ToRoleARN, "to-role-arn", "", "IAM role to assume in the accepter VPC's account")
+		rootCmd.AddCommand(cmd)
+	}
+}
+
+func peer(cmd *cobra.Command, peerFn func(*vpc.Client, PeerOptions) (*vpc.PeeringDetails, error)) {
+	opts, err := ParseConfig(globalOpts, peerOpts)
+	if err != nil {
+		fmt.Printf("Error parsing config file (%s): %s", globalOpts.ConfigFile, err)
+	}
+	if globalOpts.Verbose {
+		fmt.Println(PrettyEncode(opts))
+	}
+	cfg, err := config.LoadDefaultConfig(cmd.Context())
+	if err != nil {
+		fmt.Printf("Error getting AWS config: %s", err)
+		os.Exit(1)
+	}
+
+	vpcClient := NewVPCClient(cfg)
+	peeringDetails, err := peerFn(vpcClient, opts)
+	if err != nil {
+		fmt.Println(PrettyEncode(peeringDetails))
+		fmt.Println(err)
+		os.Exit(2)
+	}
+	fmt.Println(PrettyEncode(peeringDetails))
+}
+
+func PeerCLIOptsToVPCOpts(opts PeerOptions) vpc.PeerOptions {
+	return vpc.PeerOptions{
+		From:      opts.From,
+		To:        opts.To,
+		ToRegion:  opts.ToRegion,
+		ToRoleARN: opts.ToRoleARN,
+	}
+}
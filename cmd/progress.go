@@ -0,0 +1,221 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+
+	"github.com/bwagner5/vpcctl/pkg/vpc"
+)
+
+const (
+	stepPending    = "pending"
+	stepInProgress = "in progress"
+	stepDone       = "done"
+	stepFailed     = "failed"
+	stepSkipped    = "skipped"
+
+	// maxStepIDs is the number of resource IDs shown per step before the rest are counted
+	maxStepIDs = 3
+)
+
+var spinner = []string{"|", "/", "-", "\\"}
+
+// WithProgress runs an operation with a live progress view on stdout when stdout is a terminal and progress
+// events are narrated as text. Otherwise the operation runs with the client's observer, which writes plain lines.
+// When the operation is interrupted or times out, the steps that completed are summarized on stderr.
+func WithProgress[T any](vpcClient *vpc.Client, operation func(*vpc.Client) (T, error)) (T, error) {
+	var completed []vpc.Event
+	recorder := vpc.ObserverFunc(func(event vpc.Event) {
+		if event.Phase == vpc.PhaseSucceeded {
+			completed = append(completed, event)
+		}
+	})
+	observer := vpcClient.Observer()
+	var view *progressView
+	if !globalOpts.Quiet && globalOpts.LogFormat == "text" && isTerminal(os.Stdout) {
+		view = newProgressView(os.Stdout)
+		observer = view
+		view.start()
+	}
+	result, err := operation(vpcClient.WithObserver(vpc.Observers{observer, recorder}))
+	if view != nil {
+		view.stop()
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		fmt.Fprintf(os.Stderr, "Stopped before finishing (%s), completed steps:\n", lo.Ternary(errors.Is(err, context.Canceled), "interrupted", "timed out"))
+		for _, event := range completed {
+			fmt.Fprintf(os.Stderr, "  %s\n", event)
+		}
+	}
+	return result, err
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// progressStep is an action on a resource in the progress view
+type progressStep struct {
+	action   vpc.Action
+	resource string
+	ids      []string
+	status   string
+	started  time.Time
+	elapsed  time.Duration
+	// waiting is the latest state of the resources the step is waiting on
+	waiting string
+	message string
+	err     error
+}
+
+// progressView redraws the status of each step of an operation in place
+type progressView struct {
+	mu    sync.Mutex
+	out   io.Writer
+	steps []*progressStep
+	notes []string
+	// lines is the number of lines drawn by the last render, which are overwritten by the next one
+	lines int
+	frame int
+	stopC chan struct{}
+	doneC chan struct{}
+}
+
+func newProgressView(out io.Writer) *progressView {
+	return &progressView{out: out, stopC: make(chan struct{}), doneC: make(chan struct{})}
+}
+
+// start redraws the view periodically to update the elapsed time of the steps in progress
+func (p *progressView) start() {
+	go func() {
+		defer close(p.doneC)
+		ticker := time.NewTicker(250 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stopC:
+				return
+			case <-ticker.C:
+				p.mu.Lock()
+				p.frame++
+				p.render()
+				p.mu.Unlock()
+			}
+		}
+	}()
+}
+
+// stop draws the final state of the view
+func (p *progressView) stop() {
+	close(p.stopC)
+	<-p.doneC
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.render()
+}
+
+func (p *progressView) Observe(event vpc.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch event.Phase {
+	case vpc.PhasePending:
+		p.steps = append(p.steps, &progressStep{action: event.Action, resource: event.Resource, status: stepPending})
+	case vpc.PhaseStarted:
+		step := p.step(event)
+		step.status, step.started, step.ids = stepInProgress, event.Time, event.IDs
+	case vpc.PhaseWaiting:
+		if step, _, ok := lo.FindLastIndexOf(p.steps, func(step *progressStep) bool { return step.status == stepInProgress }); ok {
+			step.waiting = event.Message
+		}
+	case vpc.PhaseSucceeded, vpc.PhaseFailed:
+		step := p.step(event)
+		step.status, step.elapsed, step.err, step.waiting = lo.Ternary(event.Phase == vpc.PhaseSucceeded, stepDone, stepFailed), event.Duration, event.Err, ""
+		if len(event.IDs) != 0 {
+			step.ids = event.IDs
+		}
+	case vpc.PhaseSkipped:
+		step := p.step(event)
+		step.status, step.ids, step.message = stepSkipped, event.IDs, event.Message
+	case vpc.PhaseInfo:
+		p.notes = append(p.notes, event.Message)
+	}
+	p.render()
+}
+
+// step returns the first unfinished step of the event's action and resource, adding it when it was not planned
+func (p *progressView) step(event vpc.Event) *progressStep {
+	step, ok := lo.Find(p.steps, func(step *progressStep) bool {
+		return step.action == event.Action && step.resource == event.Resource && (step.status == stepPending || step.status == stepInProgress)
+	})
+	if !ok {
+		step = &progressStep{action: event.Action, resource: event.Resource, status: stepPending}
+		p.steps = append(p.steps, step)
+	}
+	return step
+}
+
+func (p *progressView) render() {
+	var b strings.Builder
+	// move the cursor up to the first line of the last render
+	if p.lines != 0 {
+		b.WriteString(fmt.Sprintf("\033[%dA", p.lines))
+	}
+	lines := append(lo.Map(p.steps, func(step *progressStep, _ int) string { return p.stepLine(step) }), p.notes...)
+	for _, line := range lines {
+		b.WriteString("\033[2K" + line + "\n")
+	}
+	p.lines = len(lines)
+	fmt.Fprint(p.out, b.String())
+}
+
+func (p *progressView) stepLine(step *progressStep) string {
+	status := step.status
+	elapsed := step.elapsed
+	if step.status == stepInProgress {
+		status = fmt.Sprintf("%s %s", spinner[p.frame%len(spinner)], status)
+		elapsed = time.Since(step.started)
+	}
+	line := fmt.Sprintf("%-13s %s %s", status, lo.Capitalize(string(step.action)), step.resource)
+	if len(step.ids) > maxStepIDs {
+		line += fmt.Sprintf(" [%s +%d]", strings.Join(step.ids[:maxStepIDs], " "), len(step.ids)-maxStepIDs)
+	} else if len(step.ids) != 0 {
+		line += fmt.Sprintf(" %v", step.ids)
+	}
+	if step.status == stepInProgress || step.status == stepDone || step.status == stepFailed {
+		line += fmt.Sprintf(" %s", elapsed.Round(time.Second))
+	}
+	if step.message != "" {
+		line += fmt.Sprintf(" (%s)", step.message)
+	}
+	if step.waiting != "" {
+		line += fmt.Sprintf(" (waiting: %s)", step.waiting)
+	}
+	if step.err != nil {
+		// the error is kept on one line so that the next render overwrites all of the view
+		line += fmt.Sprintf(": %s", strings.ReplaceAll(step.err.Error(), "\n", " "))
+	}
+	return line
+}
@@ -16,13 +16,19 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"os"
+	"path/filepath"
 
 	"dario.cat/mergo"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
+
+	"github.com/bwagner5/vpcctl/pkg/vpc"
 )
 
 var (
@@ -33,6 +39,10 @@ type GlobalOptions struct {
 	Verbose    bool
 	Version    bool
 	ConfigFile string
+	LogFormat  string
+	Quiet      bool
+	Journal    bool
+	StateDir   string
 }
 
 var (
@@ -47,6 +57,10 @@ func main() {
 	rootCmd.PersistentFlags().BoolVar(&globalOpts.Verbose, "verbose", false, "Verbose output")
 	rootCmd.PersistentFlags().BoolVar(&globalOpts.Version, "version", false, "version")
 	rootCmd.PersistentFlags().StringVarP(&globalOpts.ConfigFile, "file", "f", "", "YAML Config File")
+	rootCmd.PersistentFlags().StringVar(&globalOpts.LogFormat, "log-format", "text", "Format of progress events written to stderr: text or json")
+	rootCmd.PersistentFlags().BoolVarP(&globalOpts.Quiet, "quiet", "q", false, "Do not write progress events")
+	rootCmd.PersistentFlags().BoolVar(&globalOpts.Journal, "journal", false, "Record create and delete operations in a journal in the state directory")
+	rootCmd.PersistentFlags().StringVar(&globalOpts.StateDir, "state-dir", "", "Directory of the journals (defaults to ~/.vpcctl/state)")
 
 	rootCmd.AddCommand(&cobra.Command{Use: "completion", Hidden: true})
 	cobra.EnableCommandSorting = false
@@ -62,16 +76,66 @@ func ParseConfig[T any](globalOpts GlobalOptions, opts T) (T, error) {
 	if err != nil {
 		return opts, err
 	}
-	var parsedCreateOpts CreateOptions
-	if err := yaml.Unmarshal(configBytes, &parsedCreateOpts); err != nil {
+	var parsedOpts T
+	if err := yaml.Unmarshal(configBytes, &parsedOpts); err != nil {
 		return opts, err
 	}
-	if err := mergo.Merge(&opts, parsedCreateOpts, mergo.WithOverride); err != nil {
+	if err := mergo.Merge(&opts, parsedOpts, mergo.WithOverride); err != nil {
 		return opts, err
 	}
 	return opts, nil
 }
 
+// NewVPCClient creates a vpc client that reports progress events as configured by the global options
+func NewVPCClient(cfg aws.Config) *vpc.Client {
+	var observer vpc.Observer
+	switch {
+	case globalOpts.Quiet:
+		observer = vpc.DiscardObserver
+	case globalOpts.LogFormat == "json":
+		enc := json.NewEncoder(os.Stderr)
+		observer = vpc.ObserverFunc(func(event vpc.Event) { lo.Must0(enc.Encode(event)) })
+	case globalOpts.LogFormat == "text":
+		observer = vpc.LogObserver
+	default:
+		fmt.Printf("Invalid log format %q, must be text or json\n", globalOpts.LogFormat)
+		os.Exit(1)
+	}
+	return vpc.New(cfg).WithObserver(observer)
+}
+
+// StateDir returns the directory of the journals
+func StateDir() string {
+	if globalOpts.StateDir != "" {
+		return globalOpts.StateDir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".vpcctl", "state")
+	}
+	return filepath.Join(home, ".vpcctl", "state")
+}
+
+// Journaled runs an operation on the VPC named name and records it in the VPC's journal when journaling is enabled
+func Journaled(ctx context.Context, vpcClient *vpc.Client, name string, operation string, options any, run func(*vpc.Client) (*vpc.Details, error)) (*vpc.Details, error) {
+	if !globalOpts.Journal || name == "" {
+		return run(vpcClient)
+	}
+	journal, err := vpcClient.OpenJournal(ctx, StateDir(), name)
+	if err != nil {
+		return nil, err
+	}
+	journaledClient, err := journal.Record(vpcClient, operation, options)
+	if err != nil {
+		return nil, err
+	}
+	vpcDetails, err := run(journaledClient)
+	if journalErr := journal.Finish(vpcDetails, err); journalErr != nil && err == nil {
+		return vpcDetails, journalErr
+	}
+	return vpcDetails, err
+}
+
 func PrettyEncode(data interface{}) string {
 	var buffer bytes.Buffer
 	enc := json.NewEncoder(&buffer)
@@ -0,0 +1,347 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/bwagner5/vpcctl/pkg/vpc"
+)
+
+type ServeOptions struct {
+	Address   string `yaml:"address"`
+	TokenFile string `yaml:"tokenFile"`
+}
+
+// tokenEnvVar is the environment variable of the bearer token, which is used when no token file is set
+const tokenEnvVar = "VPCCTL_API_TOKEN"
+
+var (
+	serveOpts = ServeOptions{}
+	cmdServe  = &cobra.Command{
+		Use:   "serve [--address 127.0.0.1:8080] [--token-file token]",
+		Short: "Serve the HTTP API",
+		Long: fmt.Sprintf(`Serve a REST API to create, get, list and delete VPCs. Create and delete run asynchronously and return an operation to poll.
+Request bodies are JSON with the same schema as the YAML config files. When a token is set with --token-file or %s,
+requests need an "Authorization: Bearer <token>" header. Without a token the server only listens on a loopback address.
+
+  POST   /v1/vpcs                  create a VPC, returns an operation
+  GET    /v1/vpcs?name=glob&selector=k=v,k2=v2
+  GET    /v1/vpcs/{name}
+  DELETE /v1/vpcs/{name}           delete a VPC, returns an operation
+  GET    /v1/operations
+  GET    /v1/operations/{id}`, tokenEnvVar),
+		Args: cobra.MinimumNArgs(0),
+		Run: func(cmd *cobra.Command, _ []string) {
+			opts, err := ParseConfig(globalOpts, serveOpts)
+			if err != nil {
+				fmt.Printf("Error parsing config file (%s): %s", globalOpts.ConfigFile, err)
+			}
+			if globalOpts.Verbose {
+				fmt.Println(PrettyEncode(opts))
+			}
+			cfg, err := config.LoadDefaultConfig(cmd.Context())
+			if err != nil {
+				fmt.Printf("Error getting AWS config: %s", err)
+				os.Exit(1)
+			}
+			token := os.Getenv(tokenEnvVar)
+			if opts.TokenFile != "" {
+				tokenBytes, err := os.ReadFile(opts.TokenFile)
+				if err != nil {
+					fmt.Printf("Error reading token file: %s", err)
+					os.Exit(1)
+				}
+				token = strings.TrimSpace(string(tokenBytes))
+			}
+			if token == "" && !isLoopback(opts.Address) {
+				fmt.Printf("Refusing to serve the API on %s without a token, set --token-file or %s, or listen on a loopback address\n", opts.Address, tokenEnvVar)
+				os.Exit(1)
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			vpcClient := NewVPCClient(cfg)
+			srv := newServer(ctx, token, func(observer vpc.Observer) vpcAPI {
+				return vpcClient.WithObserver(vpc.Observers{vpcClient.Observer(), observer})
+			})
+			httpServer := &http.Server{Addr: opts.Address, Handler: srv.handler(), ReadHeaderTimeout: 10 * time.Second}
+			go func() {
+				<-ctx.Done()
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+				//nolint:contextcheck // the server context is already cancelled during shutdown
+				if err := httpServer.Shutdown(shutdownCtx); err != nil {
+					log.Printf("Error shutting down the server: %s", err)
+				}
+			}()
+			log.Printf("Serving the vpcctl API on %s", opts.Address)
+			if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				fmt.Println(err)
+				os.Exit(2)
+			}
+		},
+	}
+)
+
+func init() {
+	cmdServe.Flags().StringVar(&serveOpts.Address, "address", "127.0.0.1:8080", "Address to listen on, a non-loopback address requires a token")
+	cmdServe.Flags().StringVar(&serveOpts.TokenFile, "token-file", "", fmt.Sprintf("File with the bearer token that requests need (defaults to $%s, no auth when empty)", tokenEnvVar))
+	rootCmd.AddCommand(cmdServe)
+}
+
+// isLoopback returns true if the listen address only accepts connections from the local host
+func isLoopback(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// vpcAPI is the part of the vpc client that the server uses, so that the server can be tested with a fake client
+type vpcAPI interface {
+	Create(context.Context, vpc.CreateOptions) (*vpc.Details, error)
+	Get(context.Context, vpc.GetOptions) (*vpc.Details, error)
+	List(context.Context, vpc.ListOptions) ([]string, error)
+	Delete(context.Context, vpc.DeleteOptions) (*vpc.Details, error)
+}
+
+// operation is an asynchronous create or delete
+type operation struct {
+	ID        string       `json:"id"`
+	Operation string       `json:"operation"`
+	Name      string       `json:"name"`
+	Status    string       `json:"status"`
+	Started   time.Time    `json:"started"`
+	Finished  *time.Time   `json:"finished,omitempty"`
+	Error     string       `json:"error,omitempty"`
+	Events    []vpc.Event  `json:"events"`
+	Details   *vpc.Details `json:"details,omitempty"`
+}
+
+type server struct {
+	// ctx is the lifetime of the server, operations are cancelled when it is done
+	ctx   context.Context
+	token string
+	// client returns a vpc client that sends its events to the observer
+	client func(vpc.Observer) vpcAPI
+
+	mu         sync.Mutex
+	operations map[string]*operation
+}
+
+func newServer(ctx context.Context, token string, client func(vpc.Observer) vpcAPI) *server {
+	return &server{ctx: ctx, token: token, client: client, operations: map[string]*operation{}}
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/vpcs", s.createVPC)
+	mux.HandleFunc("GET /v1/vpcs", s.listVPCs)
+	mux.HandleFunc("GET /v1/vpcs/{name}", s.getVPC)
+	mux.HandleFunc("DELETE /v1/vpcs/{name}", s.deleteVPC)
+	mux.HandleFunc("GET /v1/operations", s.listOperations)
+	mux.HandleFunc("GET /v1/operations/{id}", s.getOperation)
+	return s.authenticate(mux)
+}
+
+// authenticate requires the bearer token on all requests when the server has a token
+func (s *server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if s.token != "" && (!ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1) {
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("a valid bearer token is required"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *server) createVPC(w http.ResponseWriter, r *http.Request) {
+	opts := CreateOptions{CIDR: vpc.DefaultCIDR}
+	if err := decodeBody(r, &opts); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if opts.Name == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("a VPC name is required"))
+		return
+	}
+	vpcOpts := CreateCLIOptsToVPCOpts(opts)
+	s.start(w, vpc.OperationCreate, opts.Name, func(ctx context.Context, client vpcAPI) (*vpc.Details, error) {
+		return client.Create(ctx, vpcOpts)
+	})
+}
+
+func (s *server) deleteVPC(w http.ResponseWriter, r *http.Request) {
+	var opts DeleteOptions
+	if err := decodeBody(r, &opts); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	name := r.PathValue("name")
+	deleteOpts := vpc.DeleteOptions{Name: name, Timeout: opts.Timeout, StepTimeout: opts.StepTimeout}
+	s.start(w, vpc.OperationDelete, name, func(ctx context.Context, client vpcAPI) (*vpc.Details, error) {
+		return client.Delete(ctx, deleteOpts)
+	})
+}
+
+func (s *server) getVPC(w http.ResponseWriter, r *http.Request) {
+	vpcDetails, err := s.client(vpc.DiscardObserver).Get(r.Context(), vpc.GetOptions{Name: r.PathValue("name")})
+	if errors.Is(err, vpc.ErrNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, vpcDetails)
+}
+
+func (s *server) listVPCs(w http.ResponseWriter, r *http.Request) {
+	listOpts := vpc.ListOptions{Name: r.URL.Query().Get("name")}
+	if selector := r.URL.Query().Get("selector"); selector != "" {
+		listOpts.Tags = map[string]string{}
+		for _, term := range strings.Split(selector, ",") {
+			key, value, ok := strings.Cut(term, "=")
+			if !ok {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("invalid selector %q, must be key=value pairs separated by commas", selector))
+				return
+			}
+			listOpts.Tags[key] = value
+		}
+	}
+	names, err := s.client(vpc.DiscardObserver).List(r.Context(), listOpts)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"vpcs": lo.CoalesceSliceOrEmpty(names, []string{})})
+}
+
+func (s *server) listOperations(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	operations := lo.Values(s.operations)
+	sort.Slice(operations, func(i, j int) bool { return operations[i].Started.Before(operations[j].Started) })
+	writeJSON(w, http.StatusOK, map[string][]*operation{"operations": operations})
+}
+
+func (s *server) getOperation(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op, ok := s.operations[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("operation %s not found", r.PathValue("id")))
+		return
+	}
+	writeJSON(w, http.StatusOK, op)
+}
+
+// start runs an operation in the background and responds with the operation to poll
+func (s *server) start(w http.ResponseWriter, operationType string, name string, run func(context.Context, vpcAPI) (*vpc.Details, error)) {
+	id, err := operationID()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	op := &operation{ID: id, Operation: operationType, Name: name, Status: vpc.OutcomeRunning, Started: time.Now(), Events: []vpc.Event{}}
+	client := s.client(vpc.ObserverFunc(func(event vpc.Event) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		op.Events = append(op.Events, event)
+	}))
+	s.mu.Lock()
+	s.operations[id] = op
+	s.mu.Unlock()
+	go func() {
+		vpcDetails, err := run(s.ctx, client)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		op.Finished = lo.ToPtr(time.Now())
+		op.Details = vpcDetails
+		op.Status = lo.Ternary(err == nil, vpc.OutcomeSucceeded, vpc.OutcomeFailed)
+		if err != nil {
+			op.Error = err.Error()
+		}
+	}()
+	w.Header().Set("Location", fmt.Sprintf("/v1/operations/%s", id))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusAccepted, op)
+}
+
+func operationID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("op-%s", hex.EncodeToString(id)), nil
+}
+
+// decodeBody decodes a JSON request body into options with YAML tags, JSON is YAML so the schema matches the config files
+func decodeBody(r *http.Request, opts any) error {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return nil
+	}
+	if err := yaml.Unmarshal(body, opts); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		log.Printf("Error writing response: %s", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,255 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"github.com/bwagner5/vpcctl/pkg/vpc"
+)
+
+// fakeVPCAPI records the options it is called with and knows the VPCs in vpcs by name
+type fakeVPCAPI struct {
+	mu         sync.Mutex
+	vpcs       map[string]string
+	createOpts []vpc.CreateOptions
+	deleteOpts []vpc.DeleteOptions
+}
+
+func (f *fakeVPCAPI) Create(_ context.Context, opts vpc.CreateOptions) (*vpc.Details, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.createOpts = append(f.createOpts, opts)
+	f.vpcs[opts.Name] = "vpc-created"
+	return &vpc.Details{VPC: &types.Vpc{VpcId: aws.String("vpc-created")}}, nil
+}
+
+func (f *fakeVPCAPI) Get(_ context.Context, opts vpc.GetOptions) (*vpc.Details, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	vpcID, ok := f.vpcs[opts.Name]
+	if !ok {
+		return nil, fmt.Errorf("VPC %s %w", opts.Name, vpc.ErrNotFound)
+	}
+	return &vpc.Details{VPC: &types.Vpc{VpcId: aws.String(vpcID)}}, nil
+}
+
+func (f *fakeVPCAPI) List(context.Context, vpc.ListOptions) ([]string, error) {
+	return nil, nil
+}
+
+func (f *fakeVPCAPI) Delete(_ context.Context, opts vpc.DeleteOptions) (*vpc.Details, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deleteOpts = append(f.deleteOpts, opts)
+	if _, ok := f.vpcs[opts.Name]; !ok {
+		return nil, fmt.Errorf("VPC %s %w", opts.Name, vpc.ErrNotFound)
+	}
+	delete(f.vpcs, opts.Name)
+	return &vpc.Details{}, nil
+}
+
+func newTestServer(t *testing.T, token string, fake *fakeVPCAPI) *httptest.Server {
+	t.Helper()
+	srv := newServer(context.Background(), token, func(vpc.Observer) vpcAPI { return fake })
+	ts := httptest.NewServer(srv.handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func doRequest(t *testing.T, method string, url string, token string, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// pollOperation gets the operation at the location until it is no longer running
+func pollOperation(t *testing.T, ts *httptest.Server, location string, token string) operation {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp := doRequest(t, http.MethodGet, ts.URL+location, token, "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s status = %d, want %d", location, resp.StatusCode, http.StatusOK)
+		}
+		var op operation
+		if err := json.NewDecoder(resp.Body).Decode(&op); err != nil {
+			t.Fatal(err)
+		}
+		if op.Status != vpc.OutcomeRunning {
+			return op
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("operation %s is still running", op.ID)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServerAuthentication(t *testing.T) {
+	ts := newTestServer(t, "secret", &fakeVPCAPI{vpcs: map[string]string{"dev": "vpc-123"}})
+	for _, tc := range []struct {
+		name       string
+		header     string
+		wantStatus int
+	}{
+		{name: "no token", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", header: "Bearer wrong", wantStatus: http.StatusUnauthorized},
+		{name: "not a bearer token", header: "Basic secret", wantStatus: http.StatusUnauthorized},
+		{name: "valid token", header: "Bearer secret", wantStatus: http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/v1/vpcs/dev", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tc.wantStatus {
+				t.Errorf("GET /v1/vpcs/dev status = %d, want %d", resp.StatusCode, tc.wantStatus)
+			}
+		})
+	}
+}
+
+func TestServerCreate(t *testing.T) {
+	fake := &fakeVPCAPI{vpcs: map[string]string{}}
+	ts := newTestServer(t, "secret", fake)
+	body := `{"name": "dev", "subnets": [{"az": "us-west-2a", "cidr": "10.0.0.0/24", "public": true}], "tags": {"team": "net"}, "nat": "none", "stepTimeout": "5m"}`
+	resp := doRequest(t, http.MethodPost, ts.URL+"/v1/vpcs", "secret", body)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("POST /v1/vpcs status = %d, want %d", resp.StatusCode, http.StatusAccepted)
+	}
+	location := resp.Header.Get("Location")
+	if !strings.HasPrefix(location, "/v1/operations/op-") {
+		t.Fatalf("Location = %q, want an operation", location)
+	}
+	op := pollOperation(t, ts, location, "secret")
+	if op.Status != vpc.OutcomeSucceeded || op.Operation != vpc.OperationCreate || op.Name != "dev" {
+		t.Errorf("operation = %s %s of %s, want a succeeded %s of dev", op.Status, op.Operation, op.Name, vpc.OperationCreate)
+	}
+	if op.Details == nil || aws.ToString(op.Details.VPC.VpcId) != "vpc-created" {
+		t.Errorf("operation details = %+v, want the created VPC", op.Details)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.createOpts) != 1 {
+		t.Fatalf("Create called %d times, want 1", len(fake.createOpts))
+	}
+	opts := fake.createOpts[0]
+	if opts.Name != "dev" || opts.CIDR != vpc.DefaultCIDR || opts.NAT != vpc.NATModeNone || opts.StepTimeout != 5*time.Minute || opts.Tags["team"] != "net" {
+		t.Errorf("Create options = %+v, want the decoded body with the default CIDR", opts)
+	}
+	if len(opts.Subnets) != 1 || opts.Subnets[0].AZ != "us-west-2a" || opts.Subnets[0].CIDR != "10.0.0.0/24" || !opts.Subnets[0].Public {
+		t.Errorf("Create subnets = %+v, want the subnet of the body", opts.Subnets)
+	}
+}
+
+func TestServerCreateInvalidBody(t *testing.T) {
+	ts := newTestServer(t, "", &fakeVPCAPI{vpcs: map[string]string{}})
+	for _, tc := range []struct {
+		name string
+		body string
+	}{
+		{name: "malformed", body: `{"name": `},
+		{name: "wrong type", body: `{"name": "dev", "subnets": "10.0.0.0/24"}`},
+		{name: "no name", body: `{"cidr": "10.1.0.0/16"}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if resp := doRequest(t, http.MethodPost, ts.URL+"/v1/vpcs", "", tc.body); resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("POST /v1/vpcs status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestServerDelete(t *testing.T) {
+	fake := &fakeVPCAPI{vpcs: map[string]string{"dev": "vpc-123"}}
+	ts := newTestServer(t, "", fake)
+	resp := doRequest(t, http.MethodDelete, ts.URL+"/v1/vpcs/dev", "", `{"timeout": "30m"}`)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("DELETE /v1/vpcs/dev status = %d, want %d", resp.StatusCode, http.StatusAccepted)
+	}
+	op := pollOperation(t, ts, resp.Header.Get("Location"), "")
+	if op.Status != vpc.OutcomeSucceeded || op.Operation != vpc.OperationDelete {
+		t.Errorf("operation = %s %s, want a succeeded %s", op.Status, op.Operation, vpc.OperationDelete)
+	}
+	fake.mu.Lock()
+	deleteOpts := fake.deleteOpts
+	fake.mu.Unlock()
+	if len(deleteOpts) != 1 || deleteOpts[0].Name != "dev" || deleteOpts[0].Timeout != 30*time.Minute {
+		t.Errorf("Delete options = %+v, want dev with a 30m timeout", deleteOpts)
+	}
+
+	// the VPC is gone, so deleting it again fails the operation
+	resp = doRequest(t, http.MethodDelete, ts.URL+"/v1/vpcs/dev", "", "")
+	if op := pollOperation(t, ts, resp.Header.Get("Location"), ""); op.Status != vpc.OutcomeFailed || op.Error == "" {
+		t.Errorf("operation = %s with error %q, want a failed operation with an error", op.Status, op.Error)
+	}
+}
+
+func TestServerNotFound(t *testing.T) {
+	ts := newTestServer(t, "", &fakeVPCAPI{vpcs: map[string]string{"dev": "vpc-123"}})
+	for _, path := range []string{"/v1/vpcs/unknown", "/v1/operations/op-unknown"} {
+		if resp := doRequest(t, http.MethodGet, ts.URL+path, "", ""); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("GET %s status = %d, want %d", path, resp.StatusCode, http.StatusNotFound)
+		}
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	for address, want := range map[string]bool{
+		"127.0.0.1:8080": true,
+		"localhost:8080": true,
+		"[::1]:8080":     true,
+		":8080":          false,
+		"0.0.0.0:8080":   false,
+		"10.0.0.5:8080":  false,
+		"8080":           false,
+	} {
+		if got := isLoopback(address); got != want {
+			t.Errorf("isLoopback(%q) = %t, want %t", address, got, want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+
+	"github.com/bwagner5/vpcctl/pkg/vpc"
+)
+
+type UsageOptions struct {
+	Name      string            `yaml:"name"`
+	ID        string            `yaml:"id"`
+	Selector  map[string]string `yaml:"selector"`
+	Threshold float64           `yaml:"threshold"`
+	Output    string            `yaml:"output"`
+}
+
+var (
+	usageOpts = UsageOptions{}
+	cmdUsage  = &cobra.Command{
+		Use:   "usage [--name my-vpc | --id vpc-123 | -l key=value] [--threshold 80] [-o json]",
+		Short: "Show the IP utilization of a VPC's subnets",
+		Long: `Show the total, used and available IPs of each subnet of a VPC and the ENIs using them by interface type and requester.
+Subnets whose utilization is above the threshold percent are reported as warnings.`,
+		Args: cobra.MinimumNArgs(0),
+		Run: func(cmd *cobra.Command, _ []string) {
+			opts, err := ParseConfig(globalOpts, usageOpts)
+			if err != nil {
+				fmt.Printf("Error parsing config file (%s): %s", globalOpts.ConfigFile, err)
+			}
+			if globalOpts.Verbose {
+				fmt.Println(PrettyEncode(opts))
+			}
+			cfg, err := config.LoadDefaultConfig(cmd.Context())
+			if err != nil {
+				fmt.Printf("Error getting AWS config: %s", err)
+				os.Exit(1)
+			}
+			if opts.Output != "text" && opts.Output != "json" {
+				fmt.Printf("Invalid output %q, must be text or json\n", opts.Output)
+				os.Exit(1)
+			}
+
+			vpcClient := NewVPCClient(cfg)
+			report, err := vpcClient.Usage(cmd.Context(), vpc.UsageOptions{
+				GetOptions: vpc.GetOptions{Name: opts.Name, ID: opts.ID, Tags: opts.Selector},
+				Threshold:  opts.Threshold,
+			})
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(2)
+			}
+			if opts.Output == "json" {
+				fmt.Println(PrettyEncode(report))
+				return
+			}
+			printUsage(report)
+		},
+	}
+)
+
+func init() {
+	cmdUsage.Flags().StringVarP(&usageOpts.Name, "name", "n", "", "Name of the VPC")
+	cmdUsage.Flags().StringVar(&usageOpts.ID, "id", "", "ID of the VPC")
+	cmdUsage.Flags().StringToStringVarP(&usageOpts.Selector, "selector", "l", nil, "Tag selector of the VPC, e.g. env=ci,team=infra")
+	cmdUsage.Flags().Float64Var(&usageOpts.Threshold, "threshold", vpc.DefaultUsageThreshold, "Utilization percent above which subnets are reported as warnings")
+	cmdUsage.Flags().StringVarP(&usageOpts.Output, "output", "o", "text", "Output format: text or json")
+	rootCmd.AddCommand(cmdUsage)
+}
+
+// printUsage prints a line per subnet with its ENIs by interface type and requester, followed by the warnings
+func printUsage(report *vpc.UsageReport) {
+	fmt.Printf("IP utilization of VPC %s\n", lo.Ternary(report.Name == "", report.VPCID, fmt.Sprintf("%s (%s)", report.VPCID, report.Name)))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SUBNET\tAZ\tTYPE\tCIDR\tTOTAL\tUSED\tAVAILABLE\tUTILIZATION\tENIS")
+	for _, subnet := range report.Subnets {
+		enis := lo.Map(subnet.ENIs, func(eni vpc.ENIUsage, _ int) string {
+			name := lo.Ternary(eni.Requester == "", eni.InterfaceType, fmt.Sprintf("%s/%s", eni.InterfaceType, eni.Requester))
+			return fmt.Sprintf("%s=%d (%d IPs)", name, eni.ENIs, eni.IPs)
+		})
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%d\t%.1f%%\t%s\n", subnet.SubnetID, subnet.AZ, subnet.Type, subnet.CIDR, subnet.TotalIPs, subnet.UsedIPs,
+			subnet.AvailableIPs, subnet.Utilization, strings.Join(enis, ", "))
+	}
+	w.Flush()
+	if len(report.Warnings) != 0 {
+		fmt.Printf("Warnings (above %g%%):\n", report.Threshold)
+	}
+	for _, warning := range report.Warnings {
+		fmt.Printf("* %s\n", warning)
+	}
+}
@@ -0,0 +1,210 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	PhaseCreating = "Creating"
+	PhaseReady    = "Ready"
+	PhaseFailed   = "Failed"
+	PhaseDeleting = "Deleting"
+
+	// ConditionReady is true when all of the VPC's resources were created
+	ConditionReady = "Ready"
+)
+
+// VPCSpec mirrors the create options of the vpcctl YAML config, the name of the VPC is the name of the resource.
+// Resources that are added to the spec after the VPC was created are created, changed or removed ones are not updated.
+type VPCSpec struct {
+	// +optional
+	CIDR string `json:"cidr,omitempty"`
+	// +optional
+	IPAMPoolID string `json:"ipamPoolID,omitempty"`
+	// +optional
+	IPAMNetmaskLength int32 `json:"ipamNetmaskLength,omitempty"`
+	// +optional
+	Subnets []SubnetSpec `json:"subnets,omitempty"`
+	// +optional
+	Tags map[string]string `json:"tags,omitempty"`
+	// +optional
+	SecurityGroups []SecurityGroupSpec `json:"securityGroups,omitempty"`
+	// +optional
+	NetworkACLs []NetworkACLSpec `json:"networkAcls,omitempty"`
+	// RestrictDefaultSecurityGroup removes all rules from the default security group
+	// +optional
+	RestrictDefaultSecurityGroup bool `json:"restrictDefaultSecurityGroup,omitempty"`
+	// +optional
+	TransitGateway *TransitGatewaySpec `json:"transitGateway,omitempty"`
+	// +kubebuilder:validation:Enum=gateway;instance;none
+	// +optional
+	NAT string `json:"nat,omitempty"`
+	// +optional
+	NATInstanceType string `json:"natInstanceType,omitempty"`
+	// +optional
+	NATInstanceAMI string `json:"natInstanceAMI,omitempty"`
+	// +optional
+	EnableDNSSupport *bool `json:"enableDnsSupport,omitempty"`
+	// +optional
+	EnableDNSHostnames *bool `json:"enableDnsHostnames,omitempty"`
+	// +optional
+	DHCPOptions *DHCPOptionsSpec `json:"dhcpOptions,omitempty"`
+	// +optional
+	PrivateHostedZone string `json:"privateHostedZone,omitempty"`
+	// StepTimeout is the longest a step waits for its resources to become available
+	// +optional
+	StepTimeout *metav1.Duration `json:"stepTimeout,omitempty"`
+}
+
+type SubnetSpec struct {
+	AZ   string `json:"az"`
+	CIDR string `json:"cidr"`
+	// +optional
+	Public bool `json:"public,omitempty"`
+}
+
+type SecurityGroupSpec struct {
+	Name string `json:"name"`
+	// +optional
+	Description string `json:"description,omitempty"`
+	// +optional
+	Ingress []SecurityGroupRuleSpec `json:"ingress,omitempty"`
+	// Egress replaces the default allow all egress rule when set
+	// +optional
+	Egress []SecurityGroupRuleSpec `json:"egress,omitempty"`
+}
+
+// SecurityGroupRuleSpec is a security group rule, exactly one of cidr, prefixListID, tier or securityGroup should be set
+type SecurityGroupRuleSpec struct {
+	Protocol string `json:"protocol"`
+	// +optional
+	FromPort int32 `json:"fromPort,omitempty"`
+	// +optional
+	ToPort int32 `json:"toPort,omitempty"`
+	// +optional
+	CIDR string `json:"cidr,omitempty"`
+	// +optional
+	PrefixListID string `json:"prefixListID,omitempty"`
+	// +optional
+	Tier string `json:"tier,omitempty"`
+	// +optional
+	SecurityGroup string `json:"securityGroup,omitempty"`
+	// +optional
+	Description string `json:"description,omitempty"`
+}
+
+type NetworkACLSpec struct {
+	Name string `json:"name"`
+	// +optional
+	Tier string `json:"tier,omitempty"`
+	// +optional
+	Ingress []NetworkACLRuleSpec `json:"ingress,omitempty"`
+	// +optional
+	Egress []NetworkACLRuleSpec `json:"egress,omitempty"`
+}
+
+// NetworkACLRuleSpec is a network ACL entry, exactly one of cidr or tier should be set
+type NetworkACLRuleSpec struct {
+	// +optional
+	RuleNumber int32  `json:"ruleNumber,omitempty"`
+	Protocol   string `json:"protocol"`
+	// +optional
+	FromPort int32 `json:"fromPort,omitempty"`
+	// +optional
+	ToPort int32 `json:"toPort,omitempty"`
+	// +optional
+	CIDR string `json:"cidr,omitempty"`
+	// +optional
+	Tier string `json:"tier,omitempty"`
+	// +optional
+	Action string `json:"action,omitempty"`
+}
+
+type TransitGatewaySpec struct {
+	ID string `json:"id"`
+	// +optional
+	Tier string `json:"tier,omitempty"`
+	// +optional
+	DedicatedSubnets bool `json:"dedicatedSubnets,omitempty"`
+	// +optional
+	Routes []string `json:"routes,omitempty"`
+}
+
+type DHCPOptionsSpec struct {
+	// +optional
+	DomainName string `json:"domainName,omitempty"`
+	// +optional
+	DNSServers []string `json:"dnsServers,omitempty"`
+	// +optional
+	NTPServers []string `json:"ntpServers,omitempty"`
+}
+
+type VPCStatus struct {
+	// +optional
+	Phase string `json:"phase,omitempty"`
+	// +optional
+	VPCID string `json:"vpcID,omitempty"`
+	// +optional
+	CIDR string `json:"cidr,omitempty"`
+	// +optional
+	Subnets []SubnetStatus `json:"subnets,omitempty"`
+	// +optional
+	SecurityGroupIDs []string `json:"securityGroupIDs,omitempty"`
+	// Message is the error of the last failed reconcile
+	// +optional
+	Message string `json:"message,omitempty"`
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+type SubnetStatus struct {
+	ID   string `json:"id"`
+	AZ   string `json:"az"`
+	CIDR string `json:"cidr"`
+	// Type is the vpcctl subnet type, PUBLIC, PRIVATE or TRANSIT_GATEWAY
+	Type string `json:"type"`
+}
+
+// VPC is a VPC managed by vpcctl
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="VPC ID",type=string,JSONPath=`.status.vpcID`
+// +kubebuilder:printcolumn:name="CIDR",type=string,JSONPath=`.status.cidr`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type VPC struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VPCSpec   `json:"spec,omitempty"`
+	Status VPCStatus `json:"status,omitempty"`
+}
+
+// VPCList is a list of VPCs
+// +kubebuilder:object:root=true
+type VPCList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VPC `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VPC{}, &VPCList{})
+}
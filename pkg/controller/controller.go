@@ -0,0 +1,303 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package controller reconciles VPC custom resources with the vpc client
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/bwagner5/vpcctl/pkg/apis/v1alpha1"
+	"github.com/bwagner5/vpcctl/pkg/vpc"
+)
+
+const (
+	// Finalizer keeps a VPC resource until its VPC is deleted
+	Finalizer = "vpcctl.io/vpc"
+	// ResourceUIDTagKey tags the resources of a VPC with the UID of the VPC resource that they were created for
+	ResourceUIDTagKey = "vpcctl.io/resource-uid"
+
+	// resyncPeriod is how often ready VPCs are checked, which recreates the VPC's missing resources
+	resyncPeriod = 10 * time.Minute
+)
+
+// VPCClient is the part of the vpc client that the controller uses, so that the controller can be tested with a fake client
+type VPCClient interface {
+	Create(context.Context, vpc.CreateOptions) (*vpc.Details, error)
+	Get(context.Context, vpc.GetOptions) (*vpc.Details, error)
+	Delete(context.Context, vpc.DeleteOptions) (*vpc.Details, error)
+}
+
+// +kubebuilder:rbac:groups=vpcctl.io,resources=vpcs,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=vpcctl.io,resources=vpcs/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=vpcctl.io,resources=vpcs/finalizers,verbs=update
+
+// Reconciler creates the VPCs of VPC resources, writes their IDs into the status, and deletes them with the resource
+type Reconciler struct {
+	Kube      client.Client
+	VPCClient VPCClient
+}
+
+// SetupWithManager registers the reconciler with a manager
+func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.VPC{}).
+		Named("vpc").
+		Complete(r)
+}
+
+func (r *Reconciler) Reconcile(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+	vpcResource := &v1alpha1.VPC{}
+	if err := r.Kube.Get(ctx, req.NamespacedName, vpcResource); err != nil {
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+	if !vpcResource.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, r.finalize(ctx, vpcResource)
+	}
+	if controllerutil.AddFinalizer(vpcResource, Finalizer) {
+		if err := r.Kube.Update(ctx, vpcResource); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+	vpcDetails, err := r.reconcileVPC(ctx, vpcResource)
+	stored := vpcResource.DeepCopy()
+	setStatus(vpcResource, vpcDetails, err)
+	if patchErr := r.Kube.Status().Patch(ctx, vpcResource, client.MergeFrom(stored)); patchErr != nil {
+		return reconcile.Result{}, patchErr
+	}
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	return reconcile.Result{RequeueAfter: resyncPeriod}, nil
+}
+
+// reconcileVPC creates the VPC's missing resources when the spec changed or the VPC is not ready, otherwise the VPC is fetched
+func (r *Reconciler) reconcileVPC(ctx context.Context, vpcResource *v1alpha1.VPC) (*vpc.Details, error) {
+	if vpcResource.Status.Phase == v1alpha1.PhaseReady && vpcResource.Status.ObservedGeneration == vpcResource.Generation {
+		vpcDetails, err := r.VPCClient.Get(ctx, vpc.GetOptions{Name: vpcResource.Name, ID: vpcResource.Status.VPCID})
+		if !errors.Is(err, vpc.ErrNotFound) {
+			return vpcDetails, err
+		}
+		log.FromContext(ctx).Info("VPC was not found, creating it again", "vpcID", vpcResource.Status.VPCID)
+	}
+	if err := r.setPhase(ctx, vpcResource, v1alpha1.PhaseCreating); err != nil {
+		return nil, err
+	}
+	if vpcResource.Status.VPCID == "" {
+		existing, err := r.findVPC(ctx, vpcResource)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil && !ownedBy(existing, vpcResource) {
+			return nil, fmt.Errorf("VPC %s already exists as %s and was not created for this resource, it is not adopted", vpcResource.Name, *existing.VpcId)
+		}
+		if existing != nil {
+			stored := vpcResource.DeepCopy()
+			vpcResource.Status.VPCID = *existing.VpcId
+			if err := r.Kube.Status().Patch(ctx, vpcResource, client.MergeFrom(stored)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return r.VPCClient.Create(ctx, CreateOptions(vpcResource))
+}
+
+// finalize deletes the VPC and removes the finalizer of the resource
+func (r *Reconciler) finalize(ctx context.Context, vpcResource *v1alpha1.VPC) error {
+	if !controllerutil.ContainsFinalizer(vpcResource, Finalizer) {
+		return nil
+	}
+	if err := r.setPhase(ctx, vpcResource, v1alpha1.PhaseDeleting); err != nil {
+		return err
+	}
+	vpcID := vpcResource.Status.VPCID
+	if vpcID == "" {
+		existing, err := r.findVPC(ctx, vpcResource)
+		if err != nil {
+			return err
+		}
+		if existing != nil && ownedBy(existing, vpcResource) {
+			vpcID = *existing.VpcId
+		}
+	}
+	// The VPC is only deleted by its ID so that a VPC with the same name that was created by someone else is kept
+	if vpcID == "" {
+		log.FromContext(ctx).Info("No VPC was created for the resource, removing the finalizer")
+	} else if _, err := r.VPCClient.Delete(ctx, vpc.DeleteOptions{ID: vpcID, StepTimeout: stepTimeout(vpcResource)}); err != nil && !errors.Is(err, vpc.ErrNotFound) {
+		stored := vpcResource.DeepCopy()
+		vpcResource.Status.Message = err.Error()
+		return errors.Join(err, r.Kube.Status().Patch(ctx, vpcResource, client.MergeFrom(stored)))
+	}
+	controllerutil.RemoveFinalizer(vpcResource, Finalizer)
+	return r.Kube.Update(ctx, vpcResource)
+}
+
+// findVPC returns the VPC named after the resource, or nil when there is none. The ID of a VPC is recorded in the status
+// after it is created, so a VPC that a reconcile created before it stopped is only found by its name.
+func (r *Reconciler) findVPC(ctx context.Context, vpcResource *v1alpha1.VPC) (*types.Vpc, error) {
+	vpcDetails, err := r.VPCClient.Get(ctx, vpc.GetOptions{Name: vpcResource.Name})
+	if errors.Is(err, vpc.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return vpcDetails.VPC, nil
+}
+
+// ownedBy returns true if the VPC is tagged with the UID of the resource
+func ownedBy(existing *types.Vpc, vpcResource *v1alpha1.VPC) bool {
+	return lo.ContainsBy(existing.Tags, func(tag types.Tag) bool {
+		return lo.FromPtr(tag.Key) == ResourceUIDTagKey && lo.FromPtr(tag.Value) == string(vpcResource.UID)
+	})
+}
+
+func (r *Reconciler) setPhase(ctx context.Context, vpcResource *v1alpha1.VPC, phase string) error {
+	if vpcResource.Status.Phase == phase {
+		return nil
+	}
+	stored := vpcResource.DeepCopy()
+	vpcResource.Status.Phase = phase
+	return r.Kube.Status().Patch(ctx, vpcResource, client.MergeFrom(stored))
+}
+
+// setStatus sets the status of the resource from the VPC's details and the error of the reconcile
+func setStatus(vpcResource *v1alpha1.VPC, vpcDetails *vpc.Details, err error) {
+	status := &vpcResource.Status
+	if vpcDetails != nil && vpcDetails.VPC != nil {
+		status.VPCID = *vpcDetails.VPC.VpcId
+		status.CIDR = *vpcDetails.VPC.CidrBlock
+	}
+	if vpcDetails != nil {
+		status.Subnets = lo.Map(vpcDetails.Subnets, func(subnet *types.Subnet, _ int) v1alpha1.SubnetStatus {
+			return v1alpha1.SubnetStatus{ID: *subnet.SubnetId, AZ: *subnet.AvailabilityZone, CIDR: *subnet.CidrBlock, Type: vpc.SubnetType(subnet)}
+		})
+		status.SecurityGroupIDs = lo.Map(vpcDetails.SecurityGroups, func(sg *types.SecurityGroup, _ int) string { return *sg.GroupId })
+	}
+	condition := metav1.Condition{Type: v1alpha1.ConditionReady, ObservedGeneration: vpcResource.Generation}
+	if err != nil {
+		status.Phase, status.Message = v1alpha1.PhaseFailed, err.Error()
+		condition.Status, condition.Reason, condition.Message = metav1.ConditionFalse, "ReconcileFailed", err.Error()
+	} else {
+		status.Phase, status.Message, status.ObservedGeneration = v1alpha1.PhaseReady, "", vpcResource.Generation
+		condition.Status, condition.Reason = metav1.ConditionTrue, "Created"
+	}
+	meta.SetStatusCondition(&status.Conditions, condition)
+}
+
+// CreateOptions returns the options that create the VPC of a resource. The resources of the VPC with the ID in the status
+// are kept, but a VPC is only resumed when its ID was recorded.
+func CreateOptions(vpcResource *v1alpha1.VPC) vpc.CreateOptions {
+	spec := vpcResource.Spec
+	opts := vpc.CreateOptions{
+		Name:              vpcResource.Name,
+		CIDR:              spec.CIDR,
+		IPAMPoolID:        spec.IPAMPoolID,
+		IPAMNetmaskLength: spec.IPAMNetmaskLength,
+		Tags:              lo.Assign(spec.Tags, map[string]string{ResourceUIDTagKey: string(vpcResource.UID)}),
+		Subnets: lo.Map(spec.Subnets, func(subnet v1alpha1.SubnetSpec, _ int) vpc.CreateSubnetOptions {
+			return vpc.CreateSubnetOptions{AZ: subnet.AZ, CIDR: subnet.CIDR, Public: subnet.Public}
+		}),
+		SecurityGroups: lo.Map(spec.SecurityGroups, func(sg v1alpha1.SecurityGroupSpec, _ int) vpc.CreateSecurityGroupOptions {
+			return vpc.CreateSecurityGroupOptions{
+				Name:        sg.Name,
+				Description: sg.Description,
+				Ingress:     lo.Map(sg.Ingress, securityGroupRuleOptions),
+				Egress:      lo.Map(sg.Egress, securityGroupRuleOptions),
+			}
+		}),
+		NetworkACLs: lo.Map(spec.NetworkACLs, func(acl v1alpha1.NetworkACLSpec, _ int) vpc.CreateNetworkACLOptions {
+			return vpc.CreateNetworkACLOptions{
+				Name:    acl.Name,
+				Tier:    acl.Tier,
+				Ingress: lo.Map(acl.Ingress, networkACLRuleOptions),
+				Egress:  lo.Map(acl.Egress, networkACLRuleOptions),
+			}
+		}),
+		RestrictDefaultSecurityGroup: spec.RestrictDefaultSecurityGroup,
+		NAT:                          spec.NAT,
+		NATInstanceType:              spec.NATInstanceType,
+		NATInstanceAMI:               spec.NATInstanceAMI,
+		EnableDNSSupport:             spec.EnableDNSSupport,
+		EnableDNSHostnames:           spec.EnableDNSHostnames,
+		PrivateHostedZone:            spec.PrivateHostedZone,
+		Resume:                       vpcResource.Status.VPCID != "",
+		ResumeID:                     vpcResource.Status.VPCID,
+		StepTimeout:                  stepTimeout(vpcResource),
+	}
+	if opts.CIDR == "" && opts.IPAMPoolID == "" {
+		opts.CIDR = vpc.DefaultCIDR
+	}
+	if spec.TransitGateway != nil {
+		opts.TransitGateway = &vpc.CreateTransitGatewayAttachmentOptions{
+			TransitGatewayID: spec.TransitGateway.ID,
+			Tier:             spec.TransitGateway.Tier,
+			DedicatedSubnets: spec.TransitGateway.DedicatedSubnets,
+			Routes:           spec.TransitGateway.Routes,
+		}
+	}
+	if spec.DHCPOptions != nil {
+		opts.DHCPOptions = &vpc.CreateDHCPOptions{
+			DomainName: spec.DHCPOptions.DomainName,
+			DNSServers: spec.DHCPOptions.DNSServers,
+			NTPServers: spec.DHCPOptions.NTPServers,
+		}
+	}
+	return opts
+}
+
+func stepTimeout(vpcResource *v1alpha1.VPC) time.Duration {
+	if vpcResource.Spec.StepTimeout == nil {
+		return 0
+	}
+	return vpcResource.Spec.StepTimeout.Duration
+}
+
+func securityGroupRuleOptions(rule v1alpha1.SecurityGroupRuleSpec, _ int) vpc.SecurityGroupRuleOptions {
+	return vpc.SecurityGroupRuleOptions{
+		Protocol:      rule.Protocol,
+		FromPort:      rule.FromPort,
+		ToPort:        rule.ToPort,
+		CIDR:          rule.CIDR,
+		PrefixListID:  rule.PrefixListID,
+		Tier:          rule.Tier,
+		SecurityGroup: rule.SecurityGroup,
+		Description:   rule.Description,
+	}
+}
+
+func networkACLRuleOptions(rule v1alpha1.NetworkACLRuleSpec, _ int) vpc.NetworkACLRuleOptions {
+	return vpc.NetworkACLRuleOptions{
+		RuleNumber: rule.RuleNumber,
+		Protocol:   rule.Protocol,
+		FromPort:   rule.FromPort,
+		ToPort:     rule.ToPort,
+		CIDR:       rule.CIDR,
+		Tier:       rule.Tier,
+		Action:     rule.Action,
+	}
+}
@@ -0,0 +1,284 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/samber/lo"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/bwagner5/vpcctl/pkg/apis/v1alpha1"
+	"github.com/bwagner5/vpcctl/pkg/vpc"
+)
+
+// fakeVPCClient keeps VPCs by name and records the options it is called with
+type fakeVPCClient struct {
+	vpcs map[string]*types.Vpc
+	// deleteErr fails deletes when it is set
+	deleteErr error
+	creates   []vpc.CreateOptions
+	deletes   []vpc.DeleteOptions
+}
+
+func newFakeVPCClient() *fakeVPCClient {
+	return &fakeVPCClient{vpcs: map[string]*types.Vpc{}}
+}
+
+// add adds a VPC with the name and tags, as if it was created outside of the controller
+func (f *fakeVPCClient) add(name string, tags map[string]string) *types.Vpc {
+	existing := &types.Vpc{
+		VpcId:     aws.String(fmt.Sprintf("vpc-%d", len(f.vpcs)+1)),
+		CidrBlock: aws.String(vpc.DefaultCIDR),
+		Tags: lo.MapToSlice(lo.Assign(tags, map[string]string{"Name": name}), func(key string, value string) types.Tag {
+			return types.Tag{Key: aws.String(key), Value: aws.String(value)}
+		}),
+	}
+	f.vpcs[name] = existing
+	return existing
+}
+
+func (f *fakeVPCClient) Create(_ context.Context, opts vpc.CreateOptions) (*vpc.Details, error) {
+	f.creates = append(f.creates, opts)
+	existing, ok := f.vpcs[opts.Name]
+	if !opts.Resume || !ok || *existing.VpcId != opts.ResumeID {
+		existing = f.add(opts.Name, opts.Tags)
+	}
+	return &vpc.Details{VPC: existing}, nil
+}
+
+func (f *fakeVPCClient) Get(_ context.Context, opts vpc.GetOptions) (*vpc.Details, error) {
+	existing, ok := f.vpcs[opts.Name]
+	if opts.Name == "" {
+		existing, ok = lo.Find(lo.Values(f.vpcs), func(v *types.Vpc) bool { return *v.VpcId == opts.ID })
+	}
+	if !ok || (opts.ID != "" && *existing.VpcId != opts.ID) {
+		return nil, fmt.Errorf("VPC %s %w", opts, vpc.ErrNotFound)
+	}
+	return &vpc.Details{VPC: existing}, nil
+}
+
+func (f *fakeVPCClient) Delete(_ context.Context, opts vpc.DeleteOptions) (*vpc.Details, error) {
+	f.deletes = append(f.deletes, opts)
+	if f.deleteErr != nil {
+		return nil, f.deleteErr
+	}
+	for name, existing := range f.vpcs {
+		if *existing.VpcId == opts.ID {
+			delete(f.vpcs, name)
+			return &vpc.Details{VPC: existing}, nil
+		}
+	}
+	return nil, fmt.Errorf("VPC %s %w", opts.ID, vpc.ErrNotFound)
+}
+
+func createResource(t *testing.T, kube client.Client, vpcResource *v1alpha1.VPC) *v1alpha1.VPC {
+	t.Helper()
+	if err := kube.Create(context.Background(), vpcResource); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		// remove the finalizer so that the resource does not outlive the test in the shared API server
+		stored := &v1alpha1.VPC{}
+		if err := kube.Get(context.Background(), client.ObjectKeyFromObject(vpcResource), stored); err == nil {
+			controllerutil.RemoveFinalizer(stored, Finalizer)
+			_ = kube.Update(context.Background(), stored)
+			_ = kube.Delete(context.Background(), stored)
+		}
+	})
+	return vpcResource
+}
+
+func reconcileResource(r *Reconciler, name string) error {
+	_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: k8stypes.NamespacedName{Name: name}})
+	return err
+}
+
+func getResource(t *testing.T, kube client.Client, name string) *v1alpha1.VPC {
+	t.Helper()
+	vpcResource := &v1alpha1.VPC{}
+	if err := kube.Get(context.Background(), k8stypes.NamespacedName{Name: name}, vpcResource); err != nil {
+		t.Fatal(err)
+	}
+	return vpcResource
+}
+
+func deleteResource(t *testing.T, kube client.Client, name string) {
+	t.Helper()
+	if err := kube.Delete(context.Background(), getResource(t, kube, name)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertDeleted(t *testing.T, kube client.Client, name string) {
+	t.Helper()
+	err := kube.Get(context.Background(), k8stypes.NamespacedName{Name: name}, &v1alpha1.VPC{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("getting the deleted resource %s returned %v, want not found", name, err)
+	}
+}
+
+func TestReconcileCreate(t *testing.T) {
+	kube, vpcClient := newKubeClient(t), newFakeVPCClient()
+	r := &Reconciler{Kube: kube, VPCClient: vpcClient}
+	created := createResource(t, kube, &v1alpha1.VPC{ObjectMeta: metav1.ObjectMeta{Name: "create", UID: "uid-create"}})
+	if err := reconcileResource(r, "create"); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	vpcResource := getResource(t, kube, "create")
+	if !controllerutil.ContainsFinalizer(vpcResource, Finalizer) {
+		t.Errorf("finalizers = %v, want %s", vpcResource.Finalizers, Finalizer)
+	}
+	if len(vpcClient.creates) != 1 {
+		t.Fatalf("Create called %d times, want 1", len(vpcClient.creates))
+	}
+	opts := vpcClient.creates[0]
+	if opts.Resume || opts.ResumeID != "" {
+		t.Errorf("Create resumed %q, want a new VPC since no VPC ID was recorded", opts.ResumeID)
+	}
+	if opts.Tags[ResourceUIDTagKey] != string(created.UID) {
+		t.Errorf("Create tags = %v, want %s=%s", opts.Tags, ResourceUIDTagKey, created.UID)
+	}
+	wantID := *vpcClient.vpcs["create"].VpcId
+	if status := vpcResource.Status; status.VPCID != wantID || status.CIDR != vpc.DefaultCIDR || status.Phase != v1alpha1.PhaseReady {
+		t.Errorf("status = %+v, want VPC %s in phase %s", status, wantID, v1alpha1.PhaseReady)
+	}
+
+	// a ready VPC is fetched by its recorded ID instead of being created again
+	if err := reconcileResource(r, "create"); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if len(vpcClient.creates) != 1 {
+		t.Errorf("Create called %d times after the VPC was ready, want 1", len(vpcClient.creates))
+	}
+}
+
+func TestReconcileResumesOwnedVPC(t *testing.T) {
+	kube, vpcClient := newKubeClient(t), newFakeVPCClient()
+	r := &Reconciler{Kube: kube, VPCClient: vpcClient}
+	created := createResource(t, kube, &v1alpha1.VPC{ObjectMeta: metav1.ObjectMeta{Name: "resume", UID: "uid-resume"}})
+	// a previous reconcile created the VPC but stopped before recording its ID
+	existing := vpcClient.add("resume", map[string]string{ResourceUIDTagKey: string(created.UID)})
+	if err := reconcileResource(r, "resume"); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if len(vpcClient.creates) != 1 || !vpcClient.creates[0].Resume || vpcClient.creates[0].ResumeID != *existing.VpcId {
+		t.Fatalf("Create calls = %+v, want one that resumes %s", vpcClient.creates, *existing.VpcId)
+	}
+	if vpcID := getResource(t, kube, "resume").Status.VPCID; vpcID != *existing.VpcId {
+		t.Errorf("status VPC ID = %s, want %s", vpcID, *existing.VpcId)
+	}
+}
+
+func TestReconcileDoesNotAdoptVPC(t *testing.T) {
+	kube, vpcClient := newKubeClient(t), newFakeVPCClient()
+	r := &Reconciler{Kube: kube, VPCClient: vpcClient}
+	createResource(t, kube, &v1alpha1.VPC{ObjectMeta: metav1.ObjectMeta{Name: "taken", UID: "uid-taken"}})
+	existing := vpcClient.add("taken", map[string]string{ResourceUIDTagKey: "another-resource"})
+	if err := reconcileResource(r, "taken"); err == nil {
+		t.Fatal("Reconcile() error = nil, want an error for the VPC that was not created for the resource")
+	}
+	if len(vpcClient.creates) != 0 {
+		t.Errorf("Create called %d times, want 0", len(vpcClient.creates))
+	}
+	if status := getResource(t, kube, "taken").Status; status.VPCID != "" || status.Phase != v1alpha1.PhaseFailed || status.Message == "" {
+		t.Errorf("status = %+v, want a failed phase with a message and no VPC ID", status)
+	}
+
+	// deleting the resource keeps the VPC that was not created for it
+	deleteResource(t, kube, "taken")
+	if err := reconcileResource(r, "taken"); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if len(vpcClient.deletes) != 0 || vpcClient.vpcs["taken"] != existing {
+		t.Errorf("Delete calls = %+v, want none", vpcClient.deletes)
+	}
+	assertDeleted(t, kube, "taken")
+}
+
+func TestReconcileDelete(t *testing.T) {
+	kube, vpcClient := newKubeClient(t), newFakeVPCClient()
+	r := &Reconciler{Kube: kube, VPCClient: vpcClient}
+	createResource(t, kube, &v1alpha1.VPC{ObjectMeta: metav1.ObjectMeta{Name: "delete", UID: "uid-delete"}})
+	if err := reconcileResource(r, "delete"); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	vpcID := getResource(t, kube, "delete").Status.VPCID
+
+	deleteResource(t, kube, "delete")
+	if err := reconcileResource(r, "delete"); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if len(vpcClient.deletes) != 1 || vpcClient.deletes[0].ID != vpcID || vpcClient.deletes[0].Name != "" {
+		t.Errorf("Delete calls = %+v, want one by the ID %s only", vpcClient.deletes, vpcID)
+	}
+	if _, ok := vpcClient.vpcs["delete"]; ok {
+		t.Errorf("VPC %s was not deleted", vpcID)
+	}
+	assertDeleted(t, kube, "delete")
+}
+
+func TestReconcileDeleteNotFound(t *testing.T) {
+	kube, vpcClient := newKubeClient(t), newFakeVPCClient()
+	r := &Reconciler{Kube: kube, VPCClient: vpcClient}
+	createResource(t, kube, &v1alpha1.VPC{ObjectMeta: metav1.ObjectMeta{Name: "gone", UID: "uid-gone"}})
+	if err := reconcileResource(r, "gone"); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	// the VPC was deleted outside of the controller
+	delete(vpcClient.vpcs, "gone")
+
+	deleteResource(t, kube, "gone")
+	if err := reconcileResource(r, "gone"); err != nil {
+		t.Fatalf("Reconcile() error = %v, want the finalizer to be removed when the VPC is not found", err)
+	}
+	if len(vpcClient.deletes) != 1 {
+		t.Errorf("Delete called %d times, want 1", len(vpcClient.deletes))
+	}
+	assertDeleted(t, kube, "gone")
+}
+
+func TestReconcileDeleteError(t *testing.T) {
+	kube, vpcClient := newKubeClient(t), newFakeVPCClient()
+	r := &Reconciler{Kube: kube, VPCClient: vpcClient}
+	createResource(t, kube, &v1alpha1.VPC{ObjectMeta: metav1.ObjectMeta{Name: "stuck", UID: "uid-stuck"}})
+	if err := reconcileResource(r, "stuck"); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	vpcClient.deleteErr = errors.New("DependencyViolation")
+
+	deleteResource(t, kube, "stuck")
+	if err := reconcileResource(r, "stuck"); err == nil {
+		t.Fatal("Reconcile() error = nil, want the delete error")
+	}
+	vpcResource := getResource(t, kube, "stuck")
+	if !controllerutil.ContainsFinalizer(vpcResource, Finalizer) {
+		t.Error("the finalizer was removed although the VPC was not deleted")
+	}
+	if status := vpcResource.Status; status.Phase != v1alpha1.PhaseDeleting || status.Message != "DependencyViolation" {
+		t.Errorf("status = %+v, want phase %s with the delete error", status, v1alpha1.PhaseDeleting)
+	}
+}
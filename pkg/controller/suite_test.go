@@ -0,0 +1,74 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+
+	"github.com/bwagner5/vpcctl/pkg/apis/v1alpha1"
+)
+
+var (
+	scheme = runtime.NewScheme()
+	// restConfig is the config of the envtest API server, the tests use a fake client when it is nil
+	restConfig *rest.Config
+)
+
+// TestMain starts an API server with the VPC CRD when KUBEBUILDER_ASSETS points to the envtest binaries,
+// e.g. with KUBEBUILDER_ASSETS=$(setup-envtest use -p path), otherwise the tests run against a fake client
+func TestMain(m *testing.M) {
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		os.Exit(m.Run())
+	}
+	testEnv := &envtest.Environment{
+		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "config", "crd")},
+		ErrorIfCRDPathMissing: true,
+	}
+	var err error
+	if restConfig, err = testEnv.Start(); err != nil {
+		fmt.Printf("Error starting envtest: %s\n", err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	if err := testEnv.Stop(); err != nil {
+		fmt.Printf("Error stopping envtest: %s\n", err)
+	}
+	os.Exit(code)
+}
+
+func newKubeClient(t *testing.T) client.Client {
+	t.Helper()
+	if restConfig == nil {
+		return fake.NewClientBuilder().WithScheme(scheme).WithStatusSubresource(&v1alpha1.VPC{}).Build()
+	}
+	kubeClient, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kubeClient
+}
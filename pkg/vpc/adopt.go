@@ -0,0 +1,193 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vpc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/samber/lo"
+)
+
+// AdoptOptions tags the VPC with VPCID, which was not created by vpcctl, so that it can be managed as Name
+type AdoptOptions struct {
+	VPCID string
+	Name  string
+}
+
+// AdoptDetails describes an adopted VPC and the resources that were left unmanaged
+type AdoptDetails struct {
+	Details      *Details
+	Unclassified []UnclassifiedResource
+}
+
+// UnclassifiedResource is a resource that could not be adopted and why
+type UnclassifiedResource struct {
+	ID     string
+	Reason string
+}
+
+// Adopt inspects an existing VPC, classifies its subnets as public or private based on their route tables
+// and tags the VPC, subnets, route tables, internet gateway and NAT gateways with the vpcctl ownership tags.
+// Subnets without a default route or with a default route via a transit gateway are private. Subnets that can't be
+// classified are not tagged and are reported in the returned details, they have to be deleted before vpcctl can delete the VPC.
+func (v Client) Adopt(ctx context.Context, opts AdoptOptions) (*AdoptDetails, error) {
+	if opts.VPCID == "" || opts.Name == "" {
+		return nil, fmt.Errorf("a VPC ID and a name are required to adopt a VPC")
+	}
+	existing, err := v.findVPCs(ctx, "", map[string]string{"Name": opts.Name})
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) != 0 {
+		return nil, fmt.Errorf("a VPC named %s is already managed by vpcctl", opts.Name)
+	}
+	vpcOut, err := v.ec2Client.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{VpcIds: []string{opts.VPCID}})
+	if err != nil {
+		return nil, err
+	}
+	if len(vpcOut.Vpcs) == 0 {
+		return nil, fmt.Errorf("VPC %s not found", opts.VPCID)
+	}
+	if lo.ContainsBy(vpcOut.Vpcs[0].Tags, func(tag types.Tag) bool { return *tag.Key == CreatedByTagKey && *tag.Value == CreatedByTagValue }) {
+		return nil, fmt.Errorf("VPC %s is already managed by vpcctl", opts.VPCID)
+	}
+	vpcIDFilter := []types.Filter{{Name: aws.String("vpc-id"), Values: []string{opts.VPCID}}}
+	subnets, err := allPages(ctx, ec2.NewDescribeSubnetsPaginator(v.ec2Client, &ec2.DescribeSubnetsInput{Filters: vpcIDFilter}),
+		func(page *ec2.DescribeSubnetsOutput) []types.Subnet { return page.Subnets })
+	if err != nil {
+		return nil, err
+	}
+	routeTables, err := allPages(ctx, ec2.NewDescribeRouteTablesPaginator(v.ec2Client, &ec2.DescribeRouteTablesInput{Filters: vpcIDFilter}),
+		func(page *ec2.DescribeRouteTablesOutput) []types.RouteTable { return page.RouteTables })
+	if err != nil {
+		return nil, err
+	}
+	igws, err := allPages(ctx, ec2.NewDescribeInternetGatewaysPaginator(v.ec2Client, &ec2.DescribeInternetGatewaysInput{
+		Filters: []types.Filter{{Name: aws.String("attachment.vpc-id"), Values: []string{opts.VPCID}}},
+	}), func(page *ec2.DescribeInternetGatewaysOutput) []types.InternetGateway { return page.InternetGateways })
+	if err != nil {
+		return nil, err
+	}
+	natGWs, err := allPages(ctx, ec2.NewDescribeNatGatewaysPaginator(v.ec2Client, &ec2.DescribeNatGatewaysInput{
+		Filter: append(vpcIDFilter, types.Filter{Name: aws.String("state"), Values: []string{string(types.NatGatewayStateAvailable)}}),
+	}), func(page *ec2.DescribeNatGatewaysOutput) []types.NatGateway { return page.NatGateways })
+	if err != nil {
+		return nil, err
+	}
+
+	subnetsByType, routeTableIDs, unclassified := classifySubnets(subnets, routeTables)
+	adoptDetails := &AdoptDetails{Unclassified: unclassified}
+	ownedTags := []types.Tag{{Key: aws.String(CreatedByTagKey), Value: aws.String(CreatedByTagValue)}}
+
+	t := v.track(ActionTag, "VPC", opts.VPCID)
+	if err := t.done(v.tagResources(ctx, []string{opts.VPCID}, append(ownedTags, types.Tag{Key: aws.String("Name"), Value: aws.String(opts.Name)}))); err != nil {
+		return adoptDetails, err
+	}
+	for subnetType, subnetIDs := range subnetsByType {
+		t := v.track(ActionTag, fmt.Sprintf("%s Subnets", strings.ToLower(subnetType)), subnetIDs...)
+		if err := t.done(v.tagResources(ctx, subnetIDs, append(ownedTags, types.Tag{Key: aws.String(SubnetTypeTagKey), Value: aws.String(subnetType)}))); err != nil {
+			return adoptDetails, err
+		}
+	}
+	owned := lo.Flatten([][]string{
+		routeTableIDs,
+		lo.Map(igws, func(igw types.InternetGateway, _ int) string { return *igw.InternetGatewayId }),
+		lo.Map(natGWs, func(natGW types.NatGateway, _ int) string { return *natGW.NatGatewayId }),
+	})
+	t = v.track(ActionTag, "Route Tables, Internet Gateway and NAT Gateways", owned...)
+	if err := t.done(v.tagResources(ctx, owned, ownedTags)); err != nil {
+		return adoptDetails, err
+	}
+	for _, resource := range adoptDetails.Unclassified {
+		v.info("Unable to classify %s: %s", resource.ID, resource.Reason)
+	}
+
+	vpcDetails, err := v.Get(ctx, GetOptions{ID: opts.VPCID})
+	adoptDetails.Details = vpcDetails
+	return adoptDetails, err
+}
+
+func (v Client) tagResources(ctx context.Context, resourceIDs []string, tags []types.Tag) error {
+	if len(resourceIDs) == 0 {
+		return nil
+	}
+	_, err := v.ec2Client.CreateTags(ctx, &ec2.CreateTagsInput{Resources: resourceIDs, Tags: tags})
+	return err
+}
+
+// classifySubnets groups the subnets by subnet type and returns the non-main route tables they use
+func classifySubnets(subnets []types.Subnet, routeTables []types.RouteTable) (map[string][]string, []string, []UnclassifiedResource) {
+	subnetsByType := map[string][]string{}
+	var routeTableIDs []string
+	var unclassified []UnclassifiedResource
+	for _, subnet := range subnets {
+		routeTable, ok := subnetRouteTable(*subnet.SubnetId, routeTables)
+		if !ok {
+			unclassified = append(unclassified, UnclassifiedResource{ID: *subnet.SubnetId, Reason: "no route table"})
+			continue
+		}
+		subnetType, err := classifyRouteTable(routeTable)
+		if err != nil {
+			unclassified = append(unclassified, UnclassifiedResource{ID: *subnet.SubnetId, Reason: err.Error()})
+			continue
+		}
+		subnetsByType[subnetType] = append(subnetsByType[subnetType], *subnet.SubnetId)
+		// the main route table is deleted with the VPC, so it's not owned by vpcctl
+		if !isMainRouteTable(routeTable) {
+			routeTableIDs = append(routeTableIDs, *routeTable.RouteTableId)
+		}
+	}
+	return subnetsByType, lo.Uniq(routeTableIDs), unclassified
+}
+
+// subnetRouteTable returns the route table explicitly associated with the subnet or the VPC's main route table
+func subnetRouteTable(subnetID string, routeTables []types.RouteTable) (*types.RouteTable, bool) {
+	routeTable, ok := lo.Find(routeTables, func(rt types.RouteTable) bool {
+		return lo.ContainsBy(rt.Associations, func(association types.RouteTableAssociation) bool {
+			return lo.FromPtr(association.SubnetId) == subnetID
+		})
+	})
+	if !ok {
+		routeTable, ok = lo.Find(routeTables, func(rt types.RouteTable) bool { return isMainRouteTable(&rt) })
+	}
+	return &routeTable, ok
+}
+
+func isMainRouteTable(rt *types.RouteTable) bool {
+	return lo.ContainsBy(rt.Associations, func(association types.RouteTableAssociation) bool { return lo.FromPtr(association.Main) })
+}
+
+// classifyRouteTable returns the subnet type of subnets using the route table based on its default route,
+// isolated subnets without a default route and subnets that egress through a transit gateway are private
+func classifyRouteTable(rt *types.RouteTable) (string, error) {
+	defaultRoute, ok := lo.Find(rt.Routes, func(route types.Route) bool { return lo.FromPtr(route.DestinationCidrBlock) == "0.0.0.0/0" })
+	switch {
+	case !ok:
+		return SubnetTypePrivate, nil
+	case defaultRoute.State == types.RouteStateBlackhole:
+		return "", fmt.Errorf("the default route of route table %s is a blackhole", *rt.RouteTableId)
+	case strings.HasPrefix(lo.FromPtr(defaultRoute.GatewayId), "igw-"):
+		return SubnetTypePublic, nil
+	case defaultRoute.NatGatewayId != nil, defaultRoute.InstanceId != nil, defaultRoute.NetworkInterfaceId != nil, defaultRoute.TransitGatewayId != nil:
+		return SubnetTypePrivate, nil
+	default:
+		return "", fmt.Errorf("the default route of route table %s is not via an internet gateway, NAT or transit gateway", *rt.RouteTableId)
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vpc
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/samber/lo"
+)
+
+// CloneOptions creates a VPC named NewName with the same layout as the VPC named Name.
+// The clone is created in Region and with RoleARN credentials when set, which allows cloning
+// into another region or account. When CIDR is set, the VPC and subnet CIDRs are re-based onto it.
+type CloneOptions struct {
+	Name    string
+	NewName string
+	Region  string
+	RoleARN string
+	CIDR    string
+}
+
+// Clone creates a copy of an existing VPC's layout
+func (v Client) Clone(ctx context.Context, opts CloneOptions) (*Details, error) {
+	if opts.NewName == "" {
+		return nil, fmt.Errorf("a name is required for the new VPC")
+	}
+	if opts.NewName == opts.Name && opts.Region == "" && opts.RoleARN == "" {
+		return nil, fmt.Errorf("the new VPC must have a different name than %s when it's created in the same region and account", opts.Name)
+	}
+	t := v.track(ActionFetch, "VPC details", opts.Name)
+	source, err := v.Get(ctx, GetOptions{Name: opts.Name})
+	if err := t.done(err); err != nil {
+		return nil, err
+	}
+	target := v.targetClient(opts.Region, opts.RoleARN)
+	createOpts, err := target.cloneCreateOptions(ctx, source, opts, target.cfg.Region != v.cfg.Region)
+	if err != nil {
+		return nil, err
+	}
+	return target.Create(ctx, createOpts)
+}
+
+// cloneCreateOptions translates the source VPC into create options for the target client's region
+func (v Client) cloneCreateOptions(ctx context.Context, source *Details, opts CloneOptions, crossRegion bool) (CreateOptions, error) {
+	createOpts := source.ToCreateOptions()
+	createOpts.Name = opts.NewName
+	if opts.CIDR != "" {
+		from, err := netip.ParsePrefix(createOpts.CIDR)
+		if err != nil {
+			return createOpts, err
+		}
+		to, err := netip.ParsePrefix(opts.CIDR)
+		if err != nil {
+			return createOpts, err
+		}
+		for i, subnet := range createOpts.Subnets {
+			cidr, err := rebaseCIDR(subnet.CIDR, from, to)
+			if err != nil {
+				return createOpts, err
+			}
+			createOpts.Subnets[i].CIDR = cidr
+		}
+		createOpts.CIDR = opts.CIDR
+		createOpts.IPAMPoolID = ""
+		createOpts.IPAMNetmaskLength = 0
+	}
+	if !crossRegion {
+		return createOpts, nil
+	}
+	azs, err := v.mapAZs(ctx, lo.Map(createOpts.Subnets, func(subnet CreateSubnetOptions, _ int) string { return subnet.AZ }))
+	if err != nil {
+		return createOpts, err
+	}
+	for i, subnet := range createOpts.Subnets {
+		createOpts.Subnets[i].AZ = azs[subnet.AZ]
+	}
+	// IPAM pools and transit gateways are regional, so they can't be used from another region
+	if createOpts.IPAMPoolID != "" {
+		v.info("Not using IPAM pool %s in %s, allocating %s instead", createOpts.IPAMPoolID, v.cfg.Region, createOpts.CIDR)
+		createOpts.IPAMPoolID = ""
+		createOpts.IPAMNetmaskLength = 0
+	}
+	if createOpts.TransitGateway != nil {
+		v.info("Not attaching Transit Gateway %s in %s", createOpts.TransitGateway.TransitGatewayID, v.cfg.Region)
+		createOpts.TransitGateway = nil
+	}
+	return createOpts, nil
+}
+
+// mapAZs maps the source AZs to the client region's AZs in sorted order, e.g. us-west-2a -> us-east-2a
+func (v Client) mapAZs(ctx context.Context, sourceAZs []string) (map[string]string, error) {
+	azOut, err := v.ec2Client.DescribeAvailabilityZones(ctx, &ec2.DescribeAvailabilityZonesInput{
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("zone-type"),
+				Values: []string{"availability-zone"},
+			},
+			{
+				Name:   aws.String("state"),
+				Values: []string{string(types.AvailabilityZoneStateAvailable)},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	targetAZs := lo.Map(azOut.AvailabilityZones, func(az types.AvailabilityZone, _ int) string { return *az.ZoneName })
+	sort.Strings(targetAZs)
+	sourceAZs = lo.Uniq(sourceAZs)
+	sort.Strings(sourceAZs)
+	if len(sourceAZs) > len(targetAZs) {
+		return nil, fmt.Errorf("the VPC uses %d availability zones but %s only has %d", len(sourceAZs), v.cfg.Region, len(targetAZs))
+	}
+	return lo.SliceToMap(lo.Range(len(sourceAZs)), func(i int) (string, string) { return sourceAZs[i], targetAZs[i] }), nil
+}
+
+// rebaseCIDR moves cidr from the from prefix to the same offset in the to prefix
+func rebaseCIDR(cidr string, from netip.Prefix, to netip.Prefix) (string, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return "", err
+	}
+	if !prefix.Addr().Is4() || !to.Addr().Is4() || !from.Contains(prefix.Addr()) {
+		return "", fmt.Errorf("unable to re-base %s from %s to %s", cidr, from, to)
+	}
+	offset := binary.BigEndian.Uint32(prefix.Addr().AsSlice()) - binary.BigEndian.Uint32(from.Masked().Addr().AsSlice())
+	if prefix.Bits() < to.Bits() || uint64(offset)+(uint64(1)<<(32-prefix.Bits())) > uint64(1)<<(32-to.Bits()) {
+		return "", fmt.Errorf("subnet %s does not fit into %s", cidr, to)
+	}
+	var addr [4]byte
+	binary.BigEndian.PutUint32(addr[:], binary.BigEndian.Uint32(to.Masked().Addr().AsSlice())+offset)
+	return netip.PrefixFrom(netip.AddrFrom4(addr), prefix.Bits()).String(), nil
+}
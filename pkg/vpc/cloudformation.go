@@ -0,0 +1,250 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/samber/lo"
+)
+
+// CloudFormationTemplate is a CloudFormation template that can also be included in a CDK app with CfnInclude
+type CloudFormationTemplate struct {
+	AWSTemplateFormatVersion string                               `json:"AWSTemplateFormatVersion" yaml:"AWSTemplateFormatVersion"`
+	Description              string                               `json:"Description" yaml:"Description"`
+	Parameters               map[string]CloudFormationParameter   `json:"Parameters" yaml:"Parameters"`
+	Resources                map[string]CloudFormationResource    `json:"Resources" yaml:"Resources"`
+	Outputs                  map[string]CloudFormationOutputValue `json:"Outputs" yaml:"Outputs"`
+}
+
+type CloudFormationParameter struct {
+	Type        string `json:"Type" yaml:"Type"`
+	Default     string `json:"Default" yaml:"Default"`
+	Description string `json:"Description" yaml:"Description"`
+}
+
+type CloudFormationResource struct {
+	Type       string         `json:"Type" yaml:"Type"`
+	DependsOn  []string       `json:"DependsOn,omitempty" yaml:"DependsOn,omitempty"`
+	Properties map[string]any `json:"Properties" yaml:"Properties"`
+}
+
+type CloudFormationOutputValue struct {
+	Description string `json:"Description" yaml:"Description"`
+	Value       any    `json:"Value" yaml:"Value"`
+}
+
+// cfnRef and cfnGetAtt use the long form of the intrinsic functions so that the template is valid JSON and YAML
+func cfnRef(logicalID string) map[string]any { return map[string]any{"Ref": logicalID} }
+func cfnGetAtt(logicalID string, attribute string) map[string]any {
+	return map[string]any{"Fn::GetAtt": []string{logicalID, attribute}}
+}
+
+// OutputCloudFormation outputs a CloudFormation template in YAML that recreates the VPC
+func (d Details) OutputCloudFormation() (string, error) {
+	return encodeYAML(d.CloudFormationTemplate())
+}
+
+// OutputCloudFormationJSON outputs a CloudFormation template in JSON that recreates the VPC
+func (d Details) OutputCloudFormationJSON() (string, error) {
+	template, err := json.MarshalIndent(d.CloudFormationTemplate(), "", "  ")
+	return string(template), err
+}
+
+// CloudFormationTemplate builds a CloudFormation template with the VPC, subnets, route tables, routes,
+// associations, internet gateway and NAT gateway of the VPC. CIDRs are parameters that default to the current CIDRs.
+func (d Details) CloudFormationTemplate() CloudFormationTemplate {
+	name := lo.FromPtr(nameTag(d.VPC.Tags))
+	template := CloudFormationTemplate{
+		AWSTemplateFormatVersion: "2010-09-09",
+		Description:              fmt.Sprintf("VPC %s exported from %s by vpcctl", name, *d.VPC.VpcId),
+		Parameters: map[string]CloudFormationParameter{
+			"Name":    {Type: "String", Default: name, Description: "Name tag of the VPC"},
+			"VpcCidr": {Type: "String", Default: *d.VPC.CidrBlock, Description: "CIDR of the VPC"},
+		},
+		Resources: map[string]CloudFormationResource{
+			"VPC": {
+				Type: "AWS::EC2::VPC",
+				Properties: map[string]any{
+					"CidrBlock":          cfnRef("VpcCidr"),
+					"EnableDnsSupport":   d.EnableDNSSupport,
+					"EnableDnsHostnames": d.EnableDNSHostnames,
+					"Tags": append(lo.Filter(cfnTags(d.VPC.Tags), func(tag map[string]any, _ int) bool { return tag["Key"] != "Name" }),
+						map[string]any{"Key": "Name", "Value": cfnRef("Name")}),
+				},
+			},
+		},
+		Outputs: map[string]CloudFormationOutputValue{
+			"VpcId": {Description: "ID of the VPC", Value: cfnRef("VPC")},
+		},
+	}
+
+	subnetIDs := map[string]string{}
+	subnetIDsByType := map[string][]any{}
+	for _, subnet := range d.Subnets {
+		subnetType := SubnetType(subnet)
+		logicalID := uniqueLogicalID(template.Resources, cfnLogicalID(subnetType, "Subnet", *subnet.AvailabilityZone))
+		template.Parameters[logicalID+"Cidr"] = CloudFormationParameter{Type: "String", Default: *subnet.CidrBlock, Description: fmt.Sprintf("CIDR of the %s subnet in %s", strings.ToLower(subnetType), *subnet.AvailabilityZone)}
+		template.Resources[logicalID] = CloudFormationResource{
+			Type: "AWS::EC2::Subnet",
+			Properties: map[string]any{
+				"VpcId":               cfnRef("VPC"),
+				"AvailabilityZone":    *subnet.AvailabilityZone,
+				"CidrBlock":           cfnRef(logicalID + "Cidr"),
+				"MapPublicIpOnLaunch": lo.FromPtr(subnet.MapPublicIpOnLaunch),
+				"Tags":                cfnTags(subnet.Tags),
+			},
+		}
+		subnetIDs[*subnet.SubnetId] = logicalID
+		subnetIDsByType[subnetType] = append(subnetIDsByType[subnetType], cfnRef(logicalID))
+	}
+	for subnetType, refs := range subnetIDsByType {
+		template.Outputs[cfnLogicalID(subnetType, "Subnet", "Ids")] = CloudFormationOutputValue{
+			Description: fmt.Sprintf("Comma separated IDs of the %s subnets", strings.ToLower(subnetType)),
+			Value:       map[string]any{"Fn::Join": []any{",", refs}},
+		}
+	}
+
+	if d.InternetGateway != nil {
+		template.Resources["InternetGateway"] = CloudFormationResource{
+			Type:       "AWS::EC2::InternetGateway",
+			Properties: map[string]any{"Tags": cfnTags(d.InternetGateway.Tags)},
+		}
+		template.Resources["InternetGatewayAttachment"] = CloudFormationResource{
+			Type: "AWS::EC2::VPCGatewayAttachment",
+			Properties: map[string]any{
+				"VpcId":             cfnRef("VPC"),
+				"InternetGatewayId": cfnRef("InternetGateway"),
+			},
+		}
+	}
+	natGWIDs := map[string]string{}
+	for _, natGW := range d.NATGateways {
+		logicalID := uniqueLogicalID(template.Resources, "NATGateway")
+		template.Resources[logicalID+"EIP"] = CloudFormationResource{
+			Type:       "AWS::EC2::EIP",
+			DependsOn:  lo.Ternary(d.InternetGateway != nil, []string{"InternetGatewayAttachment"}, nil),
+			Properties: map[string]any{"Domain": "vpc"},
+		}
+		template.Resources[logicalID] = CloudFormationResource{
+			Type: "AWS::EC2::NatGateway",
+			Properties: map[string]any{
+				"AllocationId": cfnGetAtt(logicalID+"EIP", "AllocationId"),
+				"SubnetId":     cfnSubnetRef(subnetIDs, lo.FromPtr(natGW.SubnetId)),
+				"Tags":         cfnTags(natGW.Tags),
+			},
+		}
+		natGWIDs[*natGW.NatGatewayId] = logicalID
+	}
+
+	for _, rt := range d.RouteTables {
+		logicalID := uniqueLogicalID(template.Resources, cfnLogicalID(lo.Ternary(isPublicRouteTable(rt), SubnetTypePublic, SubnetTypePrivate), "RouteTable"))
+		template.Resources[logicalID] = CloudFormationResource{
+			Type: "AWS::EC2::RouteTable",
+			Properties: map[string]any{
+				"VpcId": cfnRef("VPC"),
+				"Tags":  cfnTags(rt.Tags),
+			},
+		}
+		for _, route := range rt.Routes {
+			properties := map[string]any{
+				"RouteTableId":         cfnRef(logicalID),
+				"DestinationCidrBlock": lo.FromPtr(route.DestinationCidrBlock),
+			}
+			var dependsOn []string
+			switch {
+			case route.GatewayId != nil && strings.HasPrefix(*route.GatewayId, "igw-") && d.InternetGateway != nil:
+				properties["GatewayId"] = cfnRef("InternetGateway")
+				dependsOn = []string{"InternetGatewayAttachment"}
+			case route.NatGatewayId != nil && natGWIDs[*route.NatGatewayId] != "":
+				properties["NatGatewayId"] = cfnRef(natGWIDs[*route.NatGatewayId])
+			default:
+				continue
+			}
+			template.Resources[uniqueLogicalID(template.Resources, cfnLogicalID(logicalID, lo.Ternary(lo.FromPtr(route.DestinationCidrBlock) == "0.0.0.0/0", "DefaultRoute", "Route"+lo.FromPtr(route.DestinationCidrBlock))))] = CloudFormationResource{
+				Type:       "AWS::EC2::Route",
+				DependsOn:  dependsOn,
+				Properties: properties,
+			}
+		}
+		for _, association := range rt.Associations {
+			if association.SubnetId == nil {
+				continue
+			}
+			// associations of subnets that are not part of the VPC details are named after the subnet ID
+			subnetLogicalID := lo.CoalesceOrEmpty(subnetIDs[*association.SubnetId], *association.SubnetId)
+			template.Resources[uniqueLogicalID(template.Resources, cfnLogicalID(subnetLogicalID, "RouteTableAssociation"))] = CloudFormationResource{
+				Type: "AWS::EC2::SubnetRouteTableAssociation",
+				Properties: map[string]any{
+					"SubnetId":     cfnSubnetRef(subnetIDs, *association.SubnetId),
+					"RouteTableId": cfnRef(logicalID),
+				},
+			}
+		}
+	}
+	return template
+}
+
+// cfnSubnetRef references the subnet resource of the template, subnets that are not part of the VPC details are
+// referenced by their literal subnet ID
+func cfnSubnetRef(subnetIDs map[string]string, subnetID string) any {
+	if logicalID, ok := subnetIDs[subnetID]; ok {
+		return cfnRef(logicalID)
+	}
+	return subnetID
+}
+
+// cfnTags converts the tags to CloudFormation tags, excluding tags managed by vpcctl and AWS
+func cfnTags(tags []types.Tag) []map[string]any {
+	return lo.FilterMap(tags, func(tag types.Tag, _ int) (map[string]any, bool) {
+		return map[string]any{"Key": *tag.Key, "Value": *tag.Value}, !isManagedTag(*tag.Key)
+	})
+}
+
+var nonAlphanumeric = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+// cfnLogicalID joins the parts into an alphanumeric CamelCase logical ID, e.g. PrivateSubnetUsWest2a
+func cfnLogicalID(parts ...string) string {
+	var logicalID strings.Builder
+	for _, part := range parts {
+		for _, word := range nonAlphanumeric.Split(part, -1) {
+			if word == "" {
+				continue
+			}
+			// Upper case words like PRIVATE are title cased, mixed case words are kept as is
+			if word == strings.ToUpper(word) {
+				word = strings.ToLower(word)
+			}
+			logicalID.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return logicalID.String()
+}
+
+// uniqueLogicalID appends a number to the logical ID if it is already used by a resource
+func uniqueLogicalID(resources map[string]CloudFormationResource, logicalID string) string {
+	if _, ok := resources[logicalID]; !ok {
+		return logicalID
+	}
+	for i := 2; ; i++ {
+		if _, ok := resources[fmt.Sprintf("%s%d", logicalID, i)]; !ok {
+			return fmt.Sprintf("%s%d", logicalID, i)
+		}
+	}
+}
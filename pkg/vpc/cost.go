@@ -0,0 +1,202 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vpc
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/samber/lo"
+	"gopkg.in/yaml.v3"
+)
+
+// HoursPerMonth is the number of hours AWS uses for monthly estimates
+const HoursPerMonth = 730
+
+//go:embed prices.yaml
+var embeddedPriceTable []byte
+
+// PriceTable has the prices of the billable resources per region
+type PriceTable struct {
+	// Updated is the date the prices were last checked
+	Updated string            `yaml:"updated"`
+	Regions map[string]Prices `yaml:"regions"`
+}
+
+// Prices are the on-demand prices in USD of the resources with a fixed price in a region
+type Prices struct {
+	NATGatewayHourly               float64 `yaml:"natGatewayHourly"`
+	PublicIPv4Hourly               float64 `yaml:"publicIPv4Hourly"`
+	InterfaceEndpointAZHourly      float64 `yaml:"interfaceEndpointAZHourly"`
+	TransitGatewayAttachmentHourly float64 `yaml:"transitGatewayAttachmentHourly"`
+	HostedZoneMonthly              float64 `yaml:"hostedZoneMonthly"`
+	FlowLogsPerGB                  float64 `yaml:"flowLogsPerGB"`
+}
+
+// BillableResources are the number of a VPC's resources that have a fixed hourly or monthly price
+type BillableResources struct {
+	NATGateways         int
+	NATInstances        int
+	PublicIPv4Addresses int
+	// InterfaceEndpointAZs is the number of AZs of all interface endpoints, which are billed per AZ
+	InterfaceEndpointAZs      int
+	TransitGatewayAttachments int
+	HostedZones               int
+	// FlowLogs are billed by the GB ingested
+	FlowLogs int
+}
+
+// CostItem is the monthly cost of a type of resource
+type CostItem struct {
+	Resource string
+	Count    int
+	// Unit is what UnitPrice is charged for: hour, month or GB
+	Unit      string
+	UnitPrice float64
+	Monthly   float64
+}
+
+// CostEstimate is the estimated monthly cost of a VPC's resources that have a fixed price
+type CostEstimate struct {
+	Region        string
+	PricesUpdated string
+	Items         []CostItem
+	Monthly       float64
+	// Notes are the costs that are not included in the estimate
+	Notes []string
+}
+
+// LoadPriceTable reads a price table file, the price table embedded in vpcctl is returned when path is empty
+func LoadPriceTable(path string) (*PriceTable, error) {
+	priceTableBytes := embeddedPriceTable
+	if path != "" {
+		var err error
+		if priceTableBytes, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	priceTable := &PriceTable{}
+	if err := yaml.Unmarshal(priceTableBytes, priceTable); err != nil {
+		return nil, fmt.Errorf("invalid price table %s: %w", lo.CoalesceOrEmpty(path, "(embedded)"), err)
+	}
+	return priceTable, nil
+}
+
+// Estimate estimates the monthly cost of the resources in a region, flow logs are included when flowLogGB is set
+func (t PriceTable) Estimate(region string, resources BillableResources, flowLogGB float64) (*CostEstimate, error) {
+	prices, ok := t.Regions[region]
+	if !ok {
+		return nil, fmt.Errorf("the price table does not have prices for %s, add them with a price table file", region)
+	}
+	items := []CostItem{
+		{Resource: "NAT Gateways", Count: resources.NATGateways, Unit: "hour", UnitPrice: prices.NATGatewayHourly},
+		{Resource: "Public IPv4 Addresses", Count: resources.PublicIPv4Addresses, Unit: "hour", UnitPrice: prices.PublicIPv4Hourly},
+		{Resource: "Interface Endpoint AZs", Count: resources.InterfaceEndpointAZs, Unit: "hour", UnitPrice: prices.InterfaceEndpointAZHourly},
+		{Resource: "Transit Gateway Attachments", Count: resources.TransitGatewayAttachments, Unit: "hour", UnitPrice: prices.TransitGatewayAttachmentHourly},
+		{Resource: "Private Hosted Zones", Count: resources.HostedZones, Unit: "month", UnitPrice: prices.HostedZoneMonthly},
+	}
+	for i := range items {
+		items[i].Monthly = float64(items[i].Count) * items[i].UnitPrice * lo.Ternary(items[i].Unit == "hour", HoursPerMonth, 1.0)
+	}
+	estimate := &CostEstimate{
+		Region:        region,
+		PricesUpdated: t.Updated,
+		Notes:         []string{"Data processing and data transfer are billed by usage and are not included"},
+	}
+	if resources.FlowLogs != 0 && flowLogGB != 0 {
+		items = append(items, CostItem{Resource: fmt.Sprintf("Flow Logs (%g GB ingested)", flowLogGB), Count: resources.FlowLogs, Unit: "GB", UnitPrice: prices.FlowLogsPerGB, Monthly: flowLogGB * prices.FlowLogsPerGB})
+	} else if resources.FlowLogs != 0 {
+		estimate.Notes = append(estimate.Notes, fmt.Sprintf("%d flow logs are billed by the GB ingested, set the monthly GB to include them", resources.FlowLogs))
+	}
+	if resources.NATInstances != 0 {
+		estimate.Notes = append(estimate.Notes, "The NAT instance is billed as an EC2 instance and is not included")
+	}
+	estimate.Items = lo.Filter(items, func(item CostItem, _ int) bool { return item.Count != 0 })
+	estimate.Monthly = lo.SumBy(estimate.Items, func(item CostItem) float64 { return item.Monthly })
+	return estimate, nil
+}
+
+// PlannedBillableResources returns the billable resources that Create creates with the options
+func PlannedBillableResources(opts CreateOptions) BillableResources {
+	// the default subnets have public and private subnets
+	hasPrivate := len(opts.Subnets) == 0 || lo.ContainsBy(opts.Subnets, func(subnet CreateSubnetOptions) bool { return !subnet.Public })
+	hasPublic := len(opts.Subnets) == 0 || lo.ContainsBy(opts.Subnets, func(subnet CreateSubnetOptions) bool { return subnet.Public })
+	resources := BillableResources{}
+	// The NAT gateway has an EIP and the NAT instance a public IP, both are created in a public subnet for private subnets
+	if hasPrivate && hasPublic {
+		switch opts.NAT {
+		case NATModeGateway, "":
+			resources.NATGateways = 1
+		case NATModeInstance:
+			resources.NATInstances = 1
+		}
+		resources.PublicIPv4Addresses = resources.NATGateways + resources.NATInstances
+	}
+	if opts.TransitGateway != nil {
+		resources.TransitGatewayAttachments = 1
+	}
+	if opts.PrivateHostedZone != "" {
+		resources.HostedZones = 1
+	}
+	return resources
+}
+
+// BillableResources counts the billable resources of an existing VPC, including resources that vpcctl did not create
+func (v Client) BillableResources(ctx context.Context, vpcDetails *Details) (BillableResources, error) {
+	vpcID := *vpcDetails.VPC.VpcId
+	resources := BillableResources{NATGateways: len(vpcDetails.NATGateways), NATInstances: len(optionalID(vpcDetails.NATInstance, func(instance *types.Instance) *string { return instance.InstanceId }))}
+	if vpcDetails.TransitGatewayAttachment != nil {
+		resources.TransitGatewayAttachments = 1
+	}
+	if vpcDetails.HostedZone != nil {
+		resources.HostedZones = 1
+	}
+	enis, err := allPages(ctx, ec2.NewDescribeNetworkInterfacesPaginator(v.ec2Client, &ec2.DescribeNetworkInterfacesInput{
+		Filters: []types.Filter{{Name: aws.String("vpc-id"), Values: []string{vpcID}}},
+	}), func(page *ec2.DescribeNetworkInterfacesOutput) []types.NetworkInterface {
+		return page.NetworkInterfaces
+	})
+	if err != nil {
+		return resources, err
+	}
+	resources.PublicIPv4Addresses = lo.CountBy(enis, func(eni types.NetworkInterface) bool {
+		return eni.Association != nil && eni.Association.PublicIp != nil
+	})
+	endpoints, err := allPages(ctx, ec2.NewDescribeVpcEndpointsPaginator(v.ec2Client, &ec2.DescribeVpcEndpointsInput{
+		Filters: []types.Filter{
+			{Name: aws.String("vpc-id"), Values: []string{vpcID}},
+			{Name: aws.String("vpc-endpoint-type"), Values: []string{string(types.VpcEndpointTypeInterface)}},
+			{Name: aws.String("vpc-endpoint-state"), Values: []string{"pendingAcceptance", "pending", "available"}},
+		},
+	}), func(page *ec2.DescribeVpcEndpointsOutput) []types.VpcEndpoint { return page.VpcEndpoints })
+	if err != nil {
+		return resources, err
+	}
+	resources.InterfaceEndpointAZs = lo.SumBy(endpoints, func(endpoint types.VpcEndpoint) int { return len(endpoint.SubnetIds) })
+	// flow logs can be created for the VPC and for each of its subnets
+	flowLogs, err := allPages(ctx, ec2.NewDescribeFlowLogsPaginator(v.ec2Client, &ec2.DescribeFlowLogsInput{
+		Filter: []types.Filter{{Name: aws.String("resource-id"), Values: append([]string{vpcID}, lo.Map(vpcDetails.Subnets, func(subnet *types.Subnet, _ int) string { return *subnet.SubnetId })...)}},
+	}), func(page *ec2.DescribeFlowLogsOutput) []types.FlowLog { return page.FlowLogs })
+	if err != nil {
+		return resources, err
+	}
+	resources.FlowLogs = len(flowLogs)
+	return resources, nil
+}
@@ -16,17 +16,23 @@ package vpc
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
 	"github.com/samber/lo"
 )
 
 func (v Client) createVPC(ctx context.Context, opts CreateOptions) (*types.Vpc, error) {
-	vpcOut, err := v.ec2Client.CreateVpc(ctx, &ec2.CreateVpcInput{
+	createVPCInput := &ec2.CreateVpcInput{
 		CidrBlock: &opts.CIDR,
 		TagSpecifications: []types.TagSpecification{
 			{
@@ -40,13 +46,111 @@ func (v Client) createVPC(ctx context.Context, opts CreateOptions) (*types.Vpc,
 				}),
 			},
 		},
-	})
+	}
+	// Allocate the CIDR from the IPAM pool rather than using the static CIDR. IPAM releases the allocation
+	// when the VPC is deleted. The pool ID is tagged on the VPC so that the pool shows up in get and config output.
+	if opts.IPAMPoolID != "" {
+		createVPCInput.CidrBlock = nil
+		createVPCInput.Ipv4IpamPoolId = &opts.IPAMPoolID
+		if opts.IPAMNetmaskLength != 0 {
+			createVPCInput.Ipv4NetmaskLength = &opts.IPAMNetmaskLength
+		}
+		createVPCInput.TagSpecifications[0].Tags = append(createVPCInput.TagSpecifications[0].Tags,
+			types.Tag{Key: aws.String(IPAMPoolIDTagKey), Value: &opts.IPAMPoolID})
+	}
+	vpcOut, err := v.ec2Client.CreateVpc(ctx, createVPCInput)
 	if err != nil {
 		return nil, err
 	}
 	return vpcOut.Vpc, nil
 }
 
+func (v Client) modifyDNSAttributes(ctx context.Context, vpcID string, enableDNSSupport bool, enableDNSHostnames bool) error {
+	// Can only modify 1 VPC attribute at a time
+	if _, err := v.ec2Client.ModifyVpcAttribute(ctx, &ec2.ModifyVpcAttributeInput{
+		VpcId:            &vpcID,
+		EnableDnsSupport: &types.AttributeBooleanValue{Value: aws.Bool(enableDNSSupport)},
+	}); err != nil {
+		return err
+	}
+	if _, err := v.ec2Client.ModifyVpcAttribute(ctx, &ec2.ModifyVpcAttributeInput{
+		VpcId:              &vpcID,
+		EnableDnsHostnames: &types.AttributeBooleanValue{Value: aws.Bool(enableDNSHostnames)},
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (v Client) createDHCPOptions(ctx context.Context, vpcID string, opts CreateOptions) (*types.DhcpOptions, error) {
+	dhcpConfigurations := []types.NewDhcpConfiguration{
+		{Key: aws.String("domain-name-servers"), Values: lo.Ternary(len(opts.DHCPOptions.DNSServers) != 0, opts.DHCPOptions.DNSServers, []string{"AmazonProvidedDNS"})},
+	}
+	if opts.DHCPOptions.DomainName != "" {
+		dhcpConfigurations = append(dhcpConfigurations, types.NewDhcpConfiguration{Key: aws.String("domain-name"), Values: []string{opts.DHCPOptions.DomainName}})
+	}
+	if len(opts.DHCPOptions.NTPServers) != 0 {
+		dhcpConfigurations = append(dhcpConfigurations, types.NewDhcpConfiguration{Key: aws.String("ntp-servers"), Values: opts.DHCPOptions.NTPServers})
+	}
+	dhcpOut, err := v.ec2Client.CreateDhcpOptions(ctx, &ec2.CreateDhcpOptionsInput{
+		DhcpConfigurations: dhcpConfigurations,
+		TagSpecifications: []types.TagSpecification{
+			{
+				ResourceType: types.ResourceTypeDhcpOptions,
+				Tags: lo.Flatten([][]types.Tag{
+					defaultTags,
+					{
+						{Key: aws.String("Name"), Value: &opts.Name},
+					},
+					v.userTags(opts),
+				}),
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := v.ec2Client.AssociateDhcpOptions(ctx, &ec2.AssociateDhcpOptionsInput{
+		DhcpOptionsId: dhcpOut.DhcpOptions.DhcpOptionsId,
+		VpcId:         &vpcID,
+	}); err != nil {
+		return dhcpOut.DhcpOptions, err
+	}
+	return dhcpOut.DhcpOptions, nil
+}
+
+func (v Client) createHostedZone(ctx context.Context, vpcID string, opts CreateOptions) (*route53types.HostedZone, error) {
+	hostedZoneOut, err := v.route53Client.CreateHostedZone(ctx, &route53.CreateHostedZoneInput{
+		Name:            &opts.PrivateHostedZone,
+		CallerReference: aws.String(fmt.Sprintf("%s-%d", vpcID, time.Now().UnixNano())),
+		HostedZoneConfig: &route53types.HostedZoneConfig{
+			PrivateZone: true,
+			Comment:     aws.String(fmt.Sprintf("Private hosted zone for VPC %s", opts.Name)),
+		},
+		VPC: &route53types.VPC{
+			VPCId:     &vpcID,
+			VPCRegion: route53types.VPCRegion(v.cfg.Region),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := v.route53Client.ChangeTagsForResource(ctx, &route53.ChangeTagsForResourceInput{
+		ResourceId:   aws.String(strings.TrimPrefix(*hostedZoneOut.HostedZone.Id, "/hostedzone/")),
+		ResourceType: route53types.TagResourceTypeHostedzone,
+		AddTags: lo.Map(lo.Flatten([][]types.Tag{
+			defaultTags,
+			{
+				{Key: aws.String("Name"), Value: &opts.Name},
+			},
+			v.userTags(opts),
+		}), func(tag types.Tag, _ int) route53types.Tag { return route53types.Tag{Key: tag.Key, Value: tag.Value} }),
+	}); err != nil {
+		return hostedZoneOut.HostedZone, err
+	}
+	return hostedZoneOut.HostedZone, nil
+}
+
 func (v Client) createSubnets(ctx context.Context, vpcID string, opts CreateOptions) ([]*types.Subnet, error) {
 	var subnetOutputs []*ec2.CreateSubnetOutput
 	// Create subnets
@@ -96,57 +200,27 @@ func (v Client) createSubnets(ctx context.Context, vpcID string, opts CreateOpti
 	return lo.Map(subnetOutputs, func(out *ec2.CreateSubnetOutput, _ int) *types.Subnet { return out.Subnet }), nil
 }
 
-func (v Client) createRouteTables(ctx context.Context, subnets []*types.Subnet, opts CreateOptions) (map[string]*types.RouteTable, error) {
-	privateSubnets := lo.Filter(subnets, func(subnet *types.Subnet, _ int) bool { return !*subnet.MapPublicIpOnLaunch })
-	publicSubnets := lo.Filter(subnets, func(subnet *types.Subnet, _ int) bool { return *subnet.MapPublicIpOnLaunch })
-	routeTables := map[string]*types.RouteTable{}
-	// PUBLIC SUBNET RESOURCES
-	var publicRouteTableOut *ec2.CreateRouteTableOutput
-	for i, publicSubnet := range publicSubnets {
-		if i == 0 {
-			var err error
-			publicRouteTableOut, err = v.ec2Client.CreateRouteTable(ctx, &ec2.CreateRouteTableInput{
-				VpcId: publicSubnet.VpcId,
-				TagSpecifications: []types.TagSpecification{
-					{
-						ResourceType: types.ResourceTypeRouteTable,
-						Tags: lo.Flatten([][]types.Tag{
-							defaultTags,
-							{
-								{Key: aws.String("Name"), Value: aws.String(fmt.Sprintf("%s-%s", opts.Name, "PUBLIC"))},
-							},
-							v.userTags(opts),
-						}),
-					},
-				},
-			})
-			if err != nil {
-				return nil, err
-			}
-			routeTables[SubnetTypePublic] = publicRouteTableOut.RouteTable
-		}
-		if _, err := v.ec2Client.AssociateRouteTable(ctx, &ec2.AssociateRouteTableInput{
-			RouteTableId: publicRouteTableOut.RouteTable.RouteTableId,
-			SubnetId:     publicSubnet.SubnetId,
-		}); err != nil {
-			return nil, err
+// createRouteTables creates a route table per subnet type, unless one exists in routeTables, and associates the subnets
+// that are not associated with it yet
+func (v Client) createRouteTables(ctx context.Context, subnets []*types.Subnet, routeTables map[string]*types.RouteTable, opts CreateOptions) (map[string]*types.RouteTable, error) {
+	for _, subnetType := range []string{SubnetTypePublic, SubnetTypePrivate} {
+		typeSubnets := lo.Filter(subnets, func(subnet *types.Subnet, _ int) bool {
+			return SubnetType(subnet) == subnetType
+		})
+		if len(typeSubnets) == 0 {
+			continue
 		}
-	}
-
-	// PRIVATE SUBNET RESOURCES
-	var privateRouteTableOut *ec2.CreateRouteTableOutput
-	for i, privateSubnet := range privateSubnets {
-		if i == 0 {
-			var err error
-			privateRouteTableOut, err = v.ec2Client.CreateRouteTable(ctx, &ec2.CreateRouteTableInput{
-				VpcId: privateSubnet.VpcId,
+		routeTable, ok := routeTables[subnetType]
+		if !ok {
+			routeTableOut, err := v.ec2Client.CreateRouteTable(ctx, &ec2.CreateRouteTableInput{
+				VpcId: typeSubnets[0].VpcId,
 				TagSpecifications: []types.TagSpecification{
 					{
 						ResourceType: types.ResourceTypeRouteTable,
 						Tags: lo.Flatten([][]types.Tag{
 							defaultTags,
 							{
-								{Key: aws.String("Name"), Value: aws.String(fmt.Sprintf("%s-%s", opts.Name, "PRIVATE"))},
+								{Key: aws.String("Name"), Value: aws.String(fmt.Sprintf("%s-%s", opts.Name, subnetType))},
 							},
 							v.userTags(opts),
 						}),
@@ -154,27 +228,35 @@ func (v Client) createRouteTables(ctx context.Context, subnets []*types.Subnet,
 				},
 			})
 			if err != nil {
-				return nil, err
+				return routeTables, err
 			}
-			routeTables[SubnetTypePrivate] = privateRouteTableOut.RouteTable
+			routeTable = routeTableOut.RouteTable
+			routeTables[subnetType] = routeTable
 		}
-		if _, err := v.ec2Client.AssociateRouteTable(ctx, &ec2.AssociateRouteTableInput{
-			RouteTableId: privateRouteTableOut.RouteTable.RouteTableId,
-			SubnetId:     privateSubnet.SubnetId,
-		}); err != nil {
-			return nil, err
+		for _, subnet := range typeSubnets {
+			if lo.ContainsBy(routeTable.Associations, func(association types.RouteTableAssociation) bool {
+				return lo.FromPtr(association.SubnetId) == *subnet.SubnetId
+			}) {
+				continue
+			}
+			if _, err := v.ec2Client.AssociateRouteTable(ctx, &ec2.AssociateRouteTableInput{
+				RouteTableId: routeTable.RouteTableId,
+				SubnetId:     subnet.SubnetId,
+			}); err != nil {
+				return routeTables, err
+			}
 		}
 	}
 	return routeTables, nil
 }
 
 func (v Client) createNATGW(ctx context.Context, subnets []*types.Subnet, routeTable *types.RouteTable, opts CreateOptions) (*types.NatGateway, error) {
-	privateSubnets := lo.Filter(subnets, func(subnet *types.Subnet, _ int) bool { return !*subnet.MapPublicIpOnLaunch })
+	privateSubnets := lo.Filter(subnets, func(subnet *types.Subnet, _ int) bool { return SubnetType(subnet) == SubnetTypePrivate })
 	// do not create a NATGW if there are no private subnets
 	if len(privateSubnets) == 0 {
 		return nil, nil
 	}
-	publicSubnets := lo.Filter(subnets, func(subnet *types.Subnet, _ int) bool { return *subnet.MapPublicIpOnLaunch })
+	publicSubnets := lo.Filter(subnets, func(subnet *types.Subnet, _ int) bool { return SubnetType(subnet) == SubnetTypePublic })
 	eipOut, err := v.ec2Client.AllocateAddress(ctx, &ec2.AllocateAddressInput{
 		TagSpecifications: []types.TagSpecification{
 			{
@@ -211,18 +293,198 @@ func (v Client) createNATGW(ctx context.Context, subnets []*types.Subnet, routeT
 	if err != nil {
 		return nil, err
 	}
-	waiter := ec2.NewNatGatewayAvailableWaiter(v.ec2Client)
-	if err := waiter.Wait(ctx, &ec2.DescribeNatGatewaysInput{NatGatewayIds: []string{*natGWOut.NatGateway.NatGatewayId}}, 5*time.Minute); err != nil {
-		return natGWOut.NatGateway, err
+	return v.routeNATGW(ctx, natGWOut.NatGateway, routeTable, opts)
+}
+
+// routeNATGW waits for the NAT gateway to become available and routes the private route table's default route to it
+func (v Client) routeNATGW(ctx context.Context, natGW *types.NatGateway, routeTable *types.RouteTable, opts CreateOptions) (*types.NatGateway, error) {
+	if natGW.State != types.NatGatewayStateAvailable {
+		waiter := ec2.NewNatGatewayAvailableWaiter(v.ec2Client, func(o *ec2.NatGatewayAvailableWaiterOptions) {
+			o.Retryable = observeRetryable(v, "NAT Gateway", o.Retryable, natGWStates)
+		})
+		if err := waiter.Wait(ctx, &ec2.DescribeNatGatewaysInput{NatGatewayIds: []string{*natGW.NatGatewayId}}, stepTimeout(opts.StepTimeout)); err != nil {
+			return natGW, err
+		}
+		natGW.State = types.NatGatewayStateAvailable
 	}
-	if _, err := v.ec2Client.CreateRoute(ctx, &ec2.CreateRouteInput{
-		RouteTableId:         routeTable.RouteTableId,
-		DestinationCidrBlock: aws.String("0.0.0.0/0"),
-		NatGatewayId:         natGWOut.NatGateway.NatGatewayId,
+	if err := v.ensureDefaultRoute(ctx, routeTable, func(input *ec2.CreateRouteInput) { input.NatGatewayId = natGW.NatGatewayId }); err != nil {
+		return natGW, err
+	}
+	return natGW, nil
+}
+
+// ensureDefaultRoute creates the 0.0.0.0/0 route of the route table with the target set by the function,
+// unless the route table already has an active one. A blackhole default route is replaced.
+func (v Client) ensureDefaultRoute(ctx context.Context, routeTable *types.RouteTable, target func(*ec2.CreateRouteInput)) error {
+	defaultRoute, ok := lo.Find(routeTable.Routes, func(route types.Route) bool { return lo.FromPtr(route.DestinationCidrBlock) == "0.0.0.0/0" })
+	if ok && defaultRoute.State != types.RouteStateBlackhole {
+		return nil
+	}
+	input := &ec2.CreateRouteInput{RouteTableId: routeTable.RouteTableId, DestinationCidrBlock: aws.String("0.0.0.0/0")}
+	target(input)
+	if !ok {
+		_, err := v.ec2Client.CreateRoute(ctx, input)
+		return err
+	}
+	_, err := v.ec2Client.ReplaceRoute(ctx, &ec2.ReplaceRouteInput{
+		RouteTableId:         input.RouteTableId,
+		DestinationCidrBlock: input.DestinationCidrBlock,
+		NatGatewayId:         input.NatGatewayId,
+		NetworkInterfaceId:   input.NetworkInterfaceId,
+	})
+	return err
+}
+
+// natInstanceUserData configures Amazon Linux 2023 to forward and masquerade traffic from the VPC
+const natInstanceUserData = `#!/bin/bash
+set -euo pipefail
+dnf install -y iptables-services
+echo "net.ipv4.ip_forward=1" > /etc/sysctl.d/90-nat.conf
+sysctl -p /etc/sysctl.d/90-nat.conf
+IFACE=$(ip route show default | awk '{print $5}')
+iptables -t nat -A POSTROUTING -o "${IFACE}" -j MASQUERADE
+iptables -F FORWARD
+service iptables save
+systemctl enable --now iptables
+`
+
+func (v Client) createNATInstance(ctx context.Context, vpc *types.Vpc, subnets []*types.Subnet, routeTable *types.RouteTable, opts CreateOptions) (*types.Instance, *types.SecurityGroup, error) {
+	privateSubnets := lo.Filter(subnets, func(subnet *types.Subnet, _ int) bool { return SubnetType(subnet) == SubnetTypePrivate })
+	// do not create a NAT instance if there are no private subnets
+	if len(privateSubnets) == 0 {
+		return nil, nil, nil
+	}
+	publicSubnets := lo.Filter(subnets, func(subnet *types.Subnet, _ int) bool { return SubnetType(subnet) == SubnetTypePublic })
+	if len(publicSubnets) == 0 {
+		return nil, nil, fmt.Errorf("a public subnet is required for a NAT instance")
+	}
+	instanceType := lo.CoalesceOrEmpty(opts.NATInstanceType, defaultNATInstanceType)
+	imageID := opts.NATInstanceAMI
+	if imageID == "" {
+		var err error
+		if imageID, err = v.latestAL2023AMI(ctx, instanceType); err != nil {
+			return nil, nil, err
+		}
+	}
+	sgName := fmt.Sprintf("%s-nat", opts.Name)
+	sgOut, err := v.ec2Client.CreateSecurityGroup(ctx, &ec2.CreateSecurityGroupInput{
+		VpcId:       vpc.VpcId,
+		GroupName:   &sgName,
+		Description: aws.String("NAT instance traffic from the VPC"),
+		TagSpecifications: []types.TagSpecification{
+			{
+				ResourceType: types.ResourceTypeSecurityGroup,
+				Tags: lo.Flatten([][]types.Tag{
+					defaultTags,
+					{
+						{Key: aws.String("Name"), Value: &sgName},
+					},
+					v.userTags(opts),
+				}),
+			},
+		},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	natSG := &types.SecurityGroup{GroupId: sgOut.GroupId, GroupName: &sgName, VpcId: vpc.VpcId}
+	if _, err := v.ec2Client.AuthorizeSecurityGroupIngress(ctx, &ec2.AuthorizeSecurityGroupIngressInput{
+		GroupId: sgOut.GroupId,
+		IpPermissions: []types.IpPermission{{
+			IpProtocol: aws.String("-1"),
+			IpRanges:   lo.Map(vpcCIDRs(vpc), func(cidr string, _ int) types.IpRange { return types.IpRange{CidrIp: aws.String(cidr)} }),
+		}},
 	}); err != nil {
-		return nil, err
+		return nil, natSG, err
 	}
-	return natGWOut.NatGateway, nil
+	instanceOut, err := v.ec2Client.RunInstances(ctx, &ec2.RunInstancesInput{
+		ImageId:          &imageID,
+		InstanceType:     types.InstanceType(instanceType),
+		MinCount:         aws.Int32(1),
+		MaxCount:         aws.Int32(1),
+		SubnetId:         publicSubnets[0].SubnetId,
+		SecurityGroupIds: []string{*sgOut.GroupId},
+		UserData:         aws.String(base64.StdEncoding.EncodeToString([]byte(natInstanceUserData))),
+		MetadataOptions:  &types.InstanceMetadataOptionsRequest{HttpTokens: types.HttpTokensStateRequired},
+		TagSpecifications: []types.TagSpecification{
+			{
+				ResourceType: types.ResourceTypeInstance,
+				Tags: lo.Flatten([][]types.Tag{
+					defaultTags,
+					{
+						{Key: aws.String("Name"), Value: aws.String(fmt.Sprintf("%s-nat", opts.Name))},
+						{Key: aws.String(NATInstanceTagKey), Value: aws.String("true")},
+					},
+					v.userTags(opts),
+				}),
+			},
+		},
+	})
+	if err != nil {
+		return nil, natSG, err
+	}
+	instance := &instanceOut.Instances[0]
+	// The NAT instance forwards traffic that is not addressed to itself
+	if _, err := v.ec2Client.ModifyInstanceAttribute(ctx, &ec2.ModifyInstanceAttributeInput{
+		InstanceId:      instance.InstanceId,
+		SourceDestCheck: &types.AttributeBooleanValue{Value: aws.Bool(false)},
+	}); err != nil {
+		return instance, natSG, err
+	}
+	return instance, natSG, v.routeNATInstance(ctx, instance, routeTable, opts)
+}
+
+// routeNATInstance waits for the NAT instance to run and routes the private route table's default route to its network interface
+func (v Client) routeNATInstance(ctx context.Context, instance *types.Instance, routeTable *types.RouteTable, opts CreateOptions) error {
+	if instance.State == nil || instance.State.Name != types.InstanceStateNameRunning {
+		waiter := ec2.NewInstanceRunningWaiter(v.ec2Client, func(o *ec2.InstanceRunningWaiterOptions) {
+			o.Retryable = observeRetryable(v, "NAT Instance", o.Retryable, instanceStates)
+		})
+		if err := waiter.Wait(ctx, &ec2.DescribeInstancesInput{InstanceIds: []string{*instance.InstanceId}}, stepTimeout(opts.StepTimeout)); err != nil {
+			return err
+		}
+	}
+	if len(instance.NetworkInterfaces) == 0 {
+		return fmt.Errorf("NAT instance %s does not have a network interface to route to", *instance.InstanceId)
+	}
+	return v.ensureDefaultRoute(ctx, routeTable, func(input *ec2.CreateRouteInput) {
+		input.NetworkInterfaceId = instance.NetworkInterfaces[0].NetworkInterfaceId
+	})
+}
+
+// latestAL2023AMI looks up the latest Amazon Linux 2023 AMI for the architecture of the instance type
+func (v Client) latestAL2023AMI(ctx context.Context, instanceType string) (string, error) {
+	instanceTypes, err := allPages(ctx, ec2.NewDescribeInstanceTypesPaginator(v.ec2Client, &ec2.DescribeInstanceTypesInput{
+		InstanceTypes: []types.InstanceType{types.InstanceType(instanceType)},
+	}), func(page *ec2.DescribeInstanceTypesOutput) []types.InstanceTypeInfo { return page.InstanceTypes })
+	if err != nil {
+		return "", err
+	}
+	if len(instanceTypes) == 0 || instanceTypes[0].ProcessorInfo == nil {
+		return "", fmt.Errorf("instance type %s not found", instanceType)
+	}
+	arch := lo.Ternary(lo.Contains(instanceTypes[0].ProcessorInfo.SupportedArchitectures, types.ArchitectureTypeArm64), "arm64", "x86_64")
+	images, err := allPages(ctx, ec2.NewDescribeImagesPaginator(v.ec2Client, &ec2.DescribeImagesInput{
+		Owners: []string{"amazon"},
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("name"),
+				Values: []string{fmt.Sprintf("al2023-ami-2023.*-kernel-*-%s", arch)},
+			},
+			{
+				Name:   aws.String("state"),
+				Values: []string{string(types.ImageStateAvailable)},
+			},
+		},
+	}), func(page *ec2.DescribeImagesOutput) []types.Image { return page.Images })
+	if err != nil {
+		return "", err
+	}
+	if len(images) == 0 {
+		return "", fmt.Errorf("no Amazon Linux 2023 %s AMI found", arch)
+	}
+	latest := lo.MaxBy(images, func(a types.Image, b types.Image) bool { return *a.CreationDate > *b.CreationDate })
+	return *latest.ImageId, nil
 }
 
 func (v Client) createIGW(ctx context.Context, vpcID string, routeTable *types.RouteTable, opts CreateOptions) (*types.InternetGateway, error) {
@@ -259,6 +521,470 @@ func (v Client) createIGW(ctx context.Context, vpcID string, routeTable *types.R
 	return igwOut.InternetGateway, nil
 }
 
+func (v Client) createTGWAttachment(ctx context.Context, vpc *types.Vpc, subnets []*types.Subnet, routeTables []*types.RouteTable, opts CreateOptions) (*types.TransitGatewayVpcAttachment, []*types.Subnet, error) {
+	tgwOpts := opts.TransitGateway
+	tier := strings.ToUpper(lo.CoalesceOrEmpty(tgwOpts.Tier, SubnetTypePrivate))
+	// A transit gateway attachment can only use one subnet per AZ
+	attachmentSubnets := lo.UniqBy(lo.Filter(subnets, func(subnet *types.Subnet, _ int) bool { return SubnetType(subnet) == tier }),
+		func(subnet *types.Subnet) string { return *subnet.AvailabilityZone })
+	if len(attachmentSubnets) == 0 {
+		return nil, nil, fmt.Errorf("no %s subnets to attach the transit gateway to", tier)
+	}
+	var dedicatedSubnets []*types.Subnet
+	if tgwOpts.DedicatedSubnets {
+		subnetCIDRs, err := lastSubnetCIDRs(*vpc.CidrBlock, 28, len(attachmentSubnets))
+		if err != nil {
+			return nil, nil, err
+		}
+		for i, subnet := range attachmentSubnets {
+			subnetName := fmt.Sprintf("%s-%s-%s", opts.Name, *subnet.AvailabilityZone, SubnetTypeTransitGateway)
+			subnetOut, err := v.ec2Client.CreateSubnet(ctx, &ec2.CreateSubnetInput{
+				VpcId:            vpc.VpcId,
+				AvailabilityZone: subnet.AvailabilityZone,
+				CidrBlock:        aws.String(subnetCIDRs[i]),
+				TagSpecifications: []types.TagSpecification{{
+					ResourceType: types.ResourceTypeSubnet,
+					Tags: lo.Flatten([][]types.Tag{
+						defaultTags,
+						{
+							{Key: aws.String("Name"), Value: &subnetName},
+							{Key: aws.String(SubnetTypeTagKey), Value: aws.String(SubnetTypeTransitGateway)},
+						},
+						v.userTags(opts),
+					}),
+				}},
+			})
+			if err != nil {
+				return nil, dedicatedSubnets, err
+			}
+			dedicatedSubnets = append(dedicatedSubnets, subnetOut.Subnet)
+		}
+		attachmentSubnets = dedicatedSubnets
+	}
+	tgwOut, err := v.ec2Client.CreateTransitGatewayVpcAttachment(ctx, &ec2.CreateTransitGatewayVpcAttachmentInput{
+		TransitGatewayId: &tgwOpts.TransitGatewayID,
+		VpcId:            vpc.VpcId,
+		SubnetIds:        lo.Map(attachmentSubnets, func(subnet *types.Subnet, _ int) string { return *subnet.SubnetId }),
+		TagSpecifications: []types.TagSpecification{
+			{
+				ResourceType: types.ResourceTypeTransitGatewayAttachment,
+				Tags: lo.Flatten([][]types.Tag{
+					defaultTags,
+					{
+						{Key: aws.String("Name"), Value: &opts.Name},
+					},
+					v.userTags(opts),
+				}),
+			},
+		},
+	})
+	if err != nil {
+		return nil, dedicatedSubnets, err
+	}
+	tgwAttachment := tgwOut.TransitGatewayVpcAttachment
+	if err := poll(ctx, 10*time.Second, stepTimeout(opts.StepTimeout), func(ctx context.Context) (bool, error) {
+		attachmentOut, err := v.ec2Client.DescribeTransitGatewayVpcAttachments(ctx, &ec2.DescribeTransitGatewayVpcAttachmentsInput{
+			TransitGatewayAttachmentIds: []string{*tgwAttachment.TransitGatewayAttachmentId},
+		})
+		if err != nil || len(attachmentOut.TransitGatewayVpcAttachments) == 0 {
+			return false, err
+		}
+		tgwAttachment = &attachmentOut.TransitGatewayVpcAttachments[0]
+		switch tgwAttachment.State {
+		case types.TransitGatewayAttachmentStateAvailable:
+			return true, nil
+		case types.TransitGatewayAttachmentStateFailed, types.TransitGatewayAttachmentStateFailing, types.TransitGatewayAttachmentStateRejected:
+			return false, fmt.Errorf("transit gateway attachment %s is %s", *tgwAttachment.TransitGatewayAttachmentId, tgwAttachment.State)
+		}
+		v.waiting("Transit Gateway Attachment", map[string]string{*tgwAttachment.TransitGatewayAttachmentId: string(tgwAttachment.State)})
+		return false, nil
+	}); err != nil {
+		return tgwAttachment, dedicatedSubnets, err
+	}
+	for _, rt := range routeTables {
+		for _, destination := range tgwOpts.Routes {
+			if _, err := v.ec2Client.CreateRoute(ctx, &ec2.CreateRouteInput{
+				RouteTableId:         rt.RouteTableId,
+				DestinationCidrBlock: aws.String(destination),
+				TransitGatewayId:     &tgwOpts.TransitGatewayID,
+			}); err != nil {
+				return tgwAttachment, dedicatedSubnets, err
+			}
+		}
+	}
+	return tgwAttachment, dedicatedSubnets, nil
+}
+
+// validateTGWSubnets checks that the dedicated transit gateway subnets at the end of the VPC CIDR do not overlap with the
+// subnets of the options, so that the conflict is reported before any resource is created. The check is skipped when
+// the attachment exists or the CIDR is not known yet because it is allocated from an IPAM pool.
+func validateTGWSubnets(vpcDetails *Details, region string, opts CreateOptions) error {
+	if opts.TransitGateway == nil || !opts.TransitGateway.DedicatedSubnets || vpcDetails.TransitGatewayAttachment != nil {
+		return nil
+	}
+	vpcCIDR := opts.CIDR
+	if vpcDetails.VPC != nil {
+		vpcCIDR = *vpcDetails.VPC.CidrBlock
+	} else if opts.IPAMPoolID != "" {
+		return nil
+	}
+	subnets := opts.Subnets
+	if len(subnets) == 0 {
+		var err error
+		if subnets, err = DefaultSubnets(region, vpcCIDR); err != nil {
+			return err
+		}
+	}
+	public := strings.ToUpper(opts.TransitGateway.Tier) == SubnetTypePublic
+	tierAZs := lo.Uniq(lo.FilterMap(subnets, func(subnet CreateSubnetOptions, _ int) (string, bool) { return subnet.AZ, subnet.Public == public }))
+	if len(tierAZs) == 0 {
+		return nil
+	}
+	tgwCIDRs, err := lastSubnetCIDRs(vpcCIDR, 28, len(tierAZs))
+	if err != nil {
+		return err
+	}
+	for _, tgwCIDR := range tgwCIDRs {
+		tgwPrefix := netip.MustParsePrefix(tgwCIDR)
+		for _, subnet := range subnets {
+			subnetPrefix, err := netip.ParsePrefix(subnet.CIDR)
+			if err != nil {
+				return fmt.Errorf("invalid subnet CIDR %s: %w", subnet.CIDR, err)
+			}
+			if subnetPrefix.Overlaps(tgwPrefix) {
+				return fmt.Errorf("subnet %s overlaps with the dedicated transit gateway subnet %s at the end of the VPC CIDR %s, leave the last %d /28s of the VPC CIDR free",
+					subnet.CIDR, tgwCIDR, vpcCIDR, len(tgwCIDRs))
+			}
+		}
+	}
+	return nil
+}
+
+// lastSubnetCIDRs returns the last count CIDRs of size bits in the VPC CIDR,
+// which is the space the default subnet layout leaves unused.
+func lastSubnetCIDRs(vpcCIDR string, bits int, count int) ([]string, error) {
+	prefix, err := netip.ParsePrefix(vpcCIDR)
+	if err != nil {
+		return nil, err
+	}
+	subnetCIDRs, err := splitCIDR(prefix.Masked(), bits-prefix.Bits())
+	if err != nil {
+		return nil, err
+	}
+	if len(subnetCIDRs) < count {
+		return nil, fmt.Errorf("VPC CIDR %s is too small for %d /%d subnets", vpcCIDR, count, bits)
+	}
+	return lo.Map(subnetCIDRs[len(subnetCIDRs)-count:], func(subnetCIDR netip.Prefix, _ int) string { return subnetCIDR.String() }), nil
+}
+
+func (v Client) restrictDefaultSecurityGroup(ctx context.Context, vpcID string) error {
+	defaultSGs, err := allPages(ctx, ec2.NewDescribeSecurityGroupsPaginator(v.ec2Client, &ec2.DescribeSecurityGroupsInput{
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []string{vpcID},
+			},
+			{
+				Name:   aws.String("group-name"),
+				Values: []string{"default"},
+			},
+		},
+	}), func(page *ec2.DescribeSecurityGroupsOutput) []types.SecurityGroup { return page.SecurityGroups })
+	if err != nil {
+		return err
+	}
+	for _, sg := range defaultSGs {
+		if len(sg.IpPermissions) != 0 {
+			if _, err := v.ec2Client.RevokeSecurityGroupIngress(ctx, &ec2.RevokeSecurityGroupIngressInput{
+				GroupId:       sg.GroupId,
+				IpPermissions: sg.IpPermissions,
+			}); err != nil {
+				return err
+			}
+		}
+		if len(sg.IpPermissionsEgress) != 0 {
+			if _, err := v.ec2Client.RevokeSecurityGroupEgress(ctx, &ec2.RevokeSecurityGroupEgressInput{
+				GroupId:       sg.GroupId,
+				IpPermissions: sg.IpPermissionsEgress,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v Client) createSecurityGroups(ctx context.Context, vpcID string, subnets []*types.Subnet, opts CreateOptions) ([]*types.SecurityGroup, error) {
+	// Create all security groups before adding rules so that rules can refer to any other managed group by name
+	groupIDs := map[string]string{}
+	for _, sgOpts := range opts.SecurityGroups {
+		groupName := fmt.Sprintf("%s-%s", opts.Name, sgOpts.Name)
+		sgOut, err := v.ec2Client.CreateSecurityGroup(ctx, &ec2.CreateSecurityGroupInput{
+			VpcId:       &vpcID,
+			GroupName:   &groupName,
+			Description: aws.String(lo.CoalesceOrEmpty(sgOpts.Description, groupName)),
+			TagSpecifications: []types.TagSpecification{
+				{
+					ResourceType: types.ResourceTypeSecurityGroup,
+					Tags: lo.Flatten([][]types.Tag{
+						defaultTags,
+						{
+							{Key: aws.String("Name"), Value: &groupName},
+						},
+						v.userTags(opts),
+					}),
+				},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		groupIDs[sgOpts.Name] = *sgOut.GroupId
+	}
+	tierCIDRs := subnetCIDRsByTier(subnets)
+	for _, sgOpts := range opts.SecurityGroups {
+		groupID := groupIDs[sgOpts.Name]
+		if len(sgOpts.Ingress) != 0 {
+			ipPermissions, err := securityGroupIPPermissions(sgOpts.Ingress, tierCIDRs, groupIDs)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ingress rule for security group %s: %w", sgOpts.Name, err)
+			}
+			if _, err := v.ec2Client.AuthorizeSecurityGroupIngress(ctx, &ec2.AuthorizeSecurityGroupIngressInput{
+				GroupId:       &groupID,
+				IpPermissions: ipPermissions,
+			}); err != nil {
+				return nil, err
+			}
+		}
+		if len(sgOpts.Egress) != 0 {
+			ipPermissions, err := securityGroupIPPermissions(sgOpts.Egress, tierCIDRs, groupIDs)
+			if err != nil {
+				return nil, fmt.Errorf("invalid egress rule for security group %s: %w", sgOpts.Name, err)
+			}
+			// Remove the default allow all egress rule since explicit egress rules were provided
+			if _, err := v.ec2Client.RevokeSecurityGroupEgress(ctx, &ec2.RevokeSecurityGroupEgressInput{
+				GroupId: &groupID,
+				IpPermissions: []types.IpPermission{{
+					IpProtocol: aws.String("-1"),
+					IpRanges:   []types.IpRange{{CidrIp: aws.String("0.0.0.0/0")}},
+				}},
+			}); err != nil {
+				return nil, err
+			}
+			if _, err := v.ec2Client.AuthorizeSecurityGroupEgress(ctx, &ec2.AuthorizeSecurityGroupEgressInput{
+				GroupId:       &groupID,
+				IpPermissions: ipPermissions,
+			}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	sgs, err := allPages(ctx, ec2.NewDescribeSecurityGroupsPaginator(v.ec2Client, &ec2.DescribeSecurityGroupsInput{GroupIds: lo.Values(groupIDs)}),
+		func(page *ec2.DescribeSecurityGroupsOutput) []types.SecurityGroup { return page.SecurityGroups })
+	if err != nil {
+		return nil, err
+	}
+	return lo.Map(sgs, func(sg types.SecurityGroup, _ int) *types.SecurityGroup { return &sg }), nil
+}
+
+func securityGroupIPPermissions(rules []SecurityGroupRuleOptions, tierCIDRs map[string][]string, groupIDs map[string]string) ([]types.IpPermission, error) {
+	var ipPermissions []types.IpPermission
+	for _, rule := range rules {
+		protocol, err := ipProtocol(rule.Protocol)
+		if err != nil {
+			return nil, err
+		}
+		ipPermission := types.IpPermission{IpProtocol: &protocol}
+		if protocol != "-1" {
+			ipPermission.FromPort = aws.Int32(rule.FromPort)
+			ipPermission.ToPort = aws.Int32(lo.Ternary(rule.ToPort == 0, rule.FromPort, rule.ToPort))
+		}
+		description := lo.EmptyableToPtr(rule.Description)
+		switch {
+		case rule.CIDR != "":
+			ipPermission.IpRanges = []types.IpRange{{CidrIp: aws.String(rule.CIDR), Description: description}}
+		case rule.PrefixListID != "":
+			ipPermission.PrefixListIds = []types.PrefixListId{{PrefixListId: aws.String(rule.PrefixListID), Description: description}}
+		case rule.Tier != "":
+			cidrs, ok := tierCIDRs[strings.ToUpper(rule.Tier)]
+			if !ok {
+				return nil, fmt.Errorf("no subnets found for tier %s", rule.Tier)
+			}
+			ipPermission.IpRanges = lo.Map(cidrs, func(cidr string, _ int) types.IpRange {
+				return types.IpRange{CidrIp: aws.String(cidr), Description: description}
+			})
+		case rule.SecurityGroup != "":
+			groupID, ok := groupIDs[rule.SecurityGroup]
+			if !ok && !strings.HasPrefix(rule.SecurityGroup, "sg-") {
+				return nil, fmt.Errorf("security group %s is not defined", rule.SecurityGroup)
+			}
+			ipPermission.UserIdGroupPairs = []types.UserIdGroupPair{{GroupId: aws.String(lo.CoalesceOrEmpty(groupID, rule.SecurityGroup)), Description: description}}
+		default:
+			return nil, fmt.Errorf("one of cidr, prefixListID, tier or securityGroup must be set")
+		}
+		ipPermissions = append(ipPermissions, ipPermission)
+	}
+	return ipPermissions, nil
+}
+
+func (v Client) createNetworkACLs(ctx context.Context, vpcID string, subnets []*types.Subnet, opts CreateOptions) ([]*types.NetworkAcl, error) {
+	// Subnets are associated with the default network ACL on creation, so the existing association IDs are needed to replace them
+	defaultACLs, err := allPages(ctx, ec2.NewDescribeNetworkAclsPaginator(v.ec2Client, &ec2.DescribeNetworkAclsInput{
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []string{vpcID},
+			},
+			{
+				Name:   aws.String("default"),
+				Values: []string{"true"},
+			},
+		},
+	}), func(page *ec2.DescribeNetworkAclsOutput) []types.NetworkAcl { return page.NetworkAcls })
+	if err != nil {
+		return nil, err
+	}
+	associationIDs := map[string]string{}
+	for _, acl := range defaultACLs {
+		for _, association := range acl.Associations {
+			associationIDs[*association.SubnetId] = *association.NetworkAclAssociationId
+		}
+	}
+	tierCIDRs := subnetCIDRsByTier(subnets)
+	var networkACLIDs []string
+	for _, aclOpts := range opts.NetworkACLs {
+		aclName := fmt.Sprintf("%s-%s", opts.Name, aclOpts.Name)
+		aclOut, err := v.ec2Client.CreateNetworkAcl(ctx, &ec2.CreateNetworkAclInput{
+			VpcId: &vpcID,
+			TagSpecifications: []types.TagSpecification{
+				{
+					ResourceType: types.ResourceTypeNetworkAcl,
+					Tags: lo.Flatten([][]types.Tag{
+						defaultTags,
+						{
+							{Key: aws.String("Name"), Value: &aclName},
+						},
+						v.userTags(opts),
+					}),
+				},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		networkACLIDs = append(networkACLIDs, *aclOut.NetworkAcl.NetworkAclId)
+		entries, err := networkACLEntries(aclOpts, tierCIDRs)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule for network ACL %s: %w", aclOpts.Name, err)
+		}
+		for _, entry := range entries {
+			if _, err := v.ec2Client.CreateNetworkAclEntry(ctx, &ec2.CreateNetworkAclEntryInput{
+				NetworkAclId: aclOut.NetworkAcl.NetworkAclId,
+				RuleNumber:   entry.RuleNumber,
+				Protocol:     entry.Protocol,
+				RuleAction:   entry.RuleAction,
+				Egress:       entry.Egress,
+				CidrBlock:    entry.CidrBlock,
+				PortRange:    entry.PortRange,
+			}); err != nil {
+				return nil, err
+			}
+		}
+		for _, subnet := range subnets {
+			subnetType := SubnetType(subnet)
+			if aclOpts.Tier != "" && !strings.EqualFold(aclOpts.Tier, subnetType) {
+				continue
+			}
+			associationID, ok := associationIDs[*subnet.SubnetId]
+			if !ok {
+				return nil, fmt.Errorf("unable to find network ACL association for subnet %s", *subnet.SubnetId)
+			}
+			associationOut, err := v.ec2Client.ReplaceNetworkAclAssociation(ctx, &ec2.ReplaceNetworkAclAssociationInput{
+				AssociationId: &associationID,
+				NetworkAclId:  aclOut.NetworkAcl.NetworkAclId,
+			})
+			if err != nil {
+				return nil, err
+			}
+			associationIDs[*subnet.SubnetId] = *associationOut.NewAssociationId
+		}
+	}
+	acls, err := allPages(ctx, ec2.NewDescribeNetworkAclsPaginator(v.ec2Client, &ec2.DescribeNetworkAclsInput{NetworkAclIds: networkACLIDs}),
+		func(page *ec2.DescribeNetworkAclsOutput) []types.NetworkAcl { return page.NetworkAcls })
+	if err != nil {
+		return nil, err
+	}
+	return lo.Map(acls, func(acl types.NetworkAcl, _ int) *types.NetworkAcl { return &acl }), nil
+}
+
+func networkACLEntries(aclOpts CreateNetworkACLOptions, tierCIDRs map[string][]string) ([]types.NetworkAclEntry, error) {
+	var entries []types.NetworkAclEntry
+	for _, egress := range []bool{false, true} {
+		rules := lo.Ternary(egress, aclOpts.Egress, aclOpts.Ingress)
+		nextRuleNumber := int32(100)
+		for _, rule := range rules {
+			protocol, err := ipProtocol(rule.Protocol)
+			if err != nil {
+				return nil, err
+			}
+			// network ACLs only accept protocol numbers
+			protocol = lo.ValueOr(map[string]string{"tcp": "6", "udp": "17", "icmp": "1"}, protocol, protocol)
+			var cidrs []string
+			switch {
+			case rule.CIDR != "":
+				cidrs = []string{rule.CIDR}
+			case rule.Tier != "":
+				var ok bool
+				if cidrs, ok = tierCIDRs[strings.ToUpper(rule.Tier)]; !ok {
+					return nil, fmt.Errorf("no subnets found for tier %s", rule.Tier)
+				}
+			default:
+				return nil, fmt.Errorf("one of cidr or tier must be set")
+			}
+			ruleNumber := lo.Ternary(rule.RuleNumber == 0, nextRuleNumber, rule.RuleNumber)
+			for i, cidr := range cidrs {
+				entry := types.NetworkAclEntry{
+					RuleNumber: aws.Int32(ruleNumber + int32(i)), //nolint:gosec // number of subnets in a tier is small
+					Protocol:   &protocol,
+					RuleAction: types.RuleAction(lo.CoalesceOrEmpty(strings.ToLower(rule.Action), string(types.RuleActionAllow))),
+					Egress:     aws.Bool(egress),
+					CidrBlock:  aws.String(cidr),
+				}
+				if protocol == "6" || protocol == "17" {
+					entry.PortRange = &types.PortRange{From: aws.Int32(rule.FromPort), To: aws.Int32(lo.Ternary(rule.ToPort == 0, rule.FromPort, rule.ToPort))}
+				}
+				entries = append(entries, entry)
+			}
+			// a tier rule takes a rule number per subnet, so the next rule starts after all of them
+			nextRuleNumber = ruleNumber + max(10, int32(len(cidrs))) //nolint:gosec // number of subnets in a tier is small
+		}
+	}
+	return entries, nil
+}
+
+// ipProtocol normalizes a protocol name to the values accepted by the EC2 API
+func ipProtocol(protocol string) (string, error) {
+	switch strings.ToLower(protocol) {
+	case "", "all", "-1":
+		return "-1", nil
+	case "tcp", "udp", "icmp":
+		return strings.ToLower(protocol), nil
+	}
+	if _, err := strconv.Atoi(protocol); err != nil {
+		return "", fmt.Errorf("invalid protocol %s", protocol)
+	}
+	return protocol, nil
+}
+
+// subnetCIDRsByTier groups subnet CIDRs by the Type tag of the subnets
+func subnetCIDRsByTier(subnets []*types.Subnet) map[string][]string {
+	return lo.MapValues(lo.GroupBy(subnets, func(subnet *types.Subnet) string {
+		return SubnetType(subnet)
+	}), func(subnets []*types.Subnet, _ string) []string {
+		return lo.Map(subnets, func(subnet *types.Subnet, _ int) string { return *subnet.CidrBlock })
+	})
+}
+
 func (v Client) userTags(opts CreateOptions) []types.Tag {
 	return lo.MapToSlice(opts.Tags, func(k string, v string) types.Tag {
 		return types.Tag{
@@ -16,25 +16,182 @@ package vpc
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strings"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/aws/smithy-go"
+	"github.com/samber/lo"
 )
 
-func (v Client) deleteNATGW(ctx context.Context, vpcDetails *Details, _ DeleteOptions) error {
-	if _, err := v.ec2Client.DeleteNatGateway(ctx, &ec2.DeleteNatGatewayInput{NatGatewayId: vpcDetails.NATGateway.NatGatewayId}); err != nil {
+// deletePeeringConnections removes the routes to the peering connections from the route tables of both VPCs and deletes
+// the peering connections. The peer VPC's routes are removed in its region, so peers in another account have to be
+// unpeered with the account's credentials first.
+func (v Client) deletePeeringConnections(ctx context.Context, vpcDetails *Details, _ DeleteOptions) error {
+	vpcID := *vpcDetails.VPC.VpcId
+	peers := lo.Map(vpcDetails.PeeringConnections, func(pcx *types.VpcPeeringConnection, _ int) *types.VpcPeeringConnectionVpcInfo {
+		return lo.Ternary(*pcx.RequesterVpcInfo.VpcId == vpcID, pcx.AccepterVpcInfo, pcx.RequesterVpcInfo)
+	})
+	for i, peer := range peers {
+		if lo.FromPtr(peer.OwnerId) != lo.FromPtr(vpcDetails.VPC.OwnerId) {
+			return fmt.Errorf("VPC %s is peered with VPC %s of account %s through %s, remove the peering with vpcctl unpeer --to-role-arn first",
+				vpcID, *peer.VpcId, lo.FromPtr(peer.OwnerId), *vpcDetails.PeeringConnections[i].VpcPeeringConnectionId)
+		}
+	}
+	for i, pcx := range vpcDetails.PeeringConnections {
+		peeringID := *pcx.VpcPeeringConnectionId
+		if err := v.deletePeeringRoutes(ctx, peeringID, vpcDetails.RouteTables); err != nil {
+			return err
+		}
+		peerClient := v.targetClient(lo.FromPtr(peers[i].Region), "")
+		peerRouteTables, err := peerClient.peeringRouteTables(ctx, *peers[i].VpcId, peeringID)
+		if err != nil {
+			return err
+		}
+		if err := peerClient.deletePeeringRoutes(ctx, peeringID, peerRouteTables); err != nil {
+			return err
+		}
+		if _, err := v.ec2Client.DeleteVpcPeeringConnection(ctx, &ec2.DeleteVpcPeeringConnectionInput{VpcPeeringConnectionId: &peeringID}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// peeringRouteTables returns the route tables of the VPC with routes to the peering connection
+func (v Client) peeringRouteTables(ctx context.Context, vpcID string, peeringID string) ([]*types.RouteTable, error) {
+	routeTables, err := allPages(ctx, ec2.NewDescribeRouteTablesPaginator(v.ec2Client, &ec2.DescribeRouteTablesInput{
+		Filters: []types.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []string{vpcID},
+			},
+			{
+				Name:   aws.String("route.vpc-peering-connection-id"),
+				Values: []string{peeringID},
+			},
+		},
+	}), func(page *ec2.DescribeRouteTablesOutput) []types.RouteTable { return page.RouteTables })
+	if err != nil {
+		return nil, err
+	}
+	return lo.Map(routeTables, func(rt types.RouteTable, _ int) *types.RouteTable { return &rt }), nil
+}
+
+func (v Client) deleteTGWAttachment(ctx context.Context, vpcDetails *Details, opts DeleteOptions) error {
+	attachmentID := vpcDetails.TransitGatewayAttachment.TransitGatewayAttachmentId
+	if _, err := v.ec2Client.DeleteTransitGatewayVpcAttachment(ctx, &ec2.DeleteTransitGatewayVpcAttachmentInput{TransitGatewayAttachmentId: attachmentID}); err != nil {
+		return err
+	}
+	// The attachment's subnets cannot be deleted until the attachment is gone
+	return poll(ctx, 10*time.Second, stepTimeout(opts.StepTimeout), func(ctx context.Context) (bool, error) {
+		attachmentOut, err := v.ec2Client.DescribeTransitGatewayVpcAttachments(ctx, &ec2.DescribeTransitGatewayVpcAttachmentsInput{
+			TransitGatewayAttachmentIds: []string{*attachmentID},
+		})
+		if err != nil {
+			return false, err
+		}
+		if len(attachmentOut.TransitGatewayVpcAttachments) == 0 || attachmentOut.TransitGatewayVpcAttachments[0].State == types.TransitGatewayAttachmentStateDeleted {
+			return true, nil
+		}
+		v.waiting("Transit Gateway Attachment", map[string]string{*attachmentID: string(attachmentOut.TransitGatewayVpcAttachments[0].State)})
+		return false, nil
+	})
+}
+
+// deleteNATGWs deletes the live and failed NAT gateways, waits for them and any NAT gateways that were already
+// being deleted to be deleted, and then releases their EIPs
+func (v Client) deleteNATGWs(ctx context.Context, vpcDetails *Details, opts DeleteOptions) error {
+	natGWs := allNATGWs(vpcDetails)
+	for _, natGW := range natGWs {
+		if natGW.State == types.NatGatewayStateDeleting {
+			continue
+		}
+		if _, err := v.ec2Client.DeleteNatGateway(ctx, &ec2.DeleteNatGatewayInput{NatGatewayId: natGW.NatGatewayId}); err != nil {
+			return err
+		}
+	}
+	waiter := ec2.NewNatGatewayDeletedWaiter(v.ec2Client, func(o *ec2.NatGatewayDeletedWaiterOptions) {
+		o.Retryable = observeRetryable(v, "NAT Gateways", o.Retryable, natGWStates)
+	})
+	natGWIDs := lo.Map(natGWs, func(natGW *types.NatGateway, _ int) string { return *natGW.NatGatewayId })
+	if err := waiter.Wait(ctx, &ec2.DescribeNatGatewaysInput{NatGatewayIds: natGWIDs}, stepTimeout(opts.StepTimeout)); err != nil {
 		return err
 	}
-	waiter := ec2.NewNatGatewayDeletedWaiter(v.ec2Client)
-	if err := waiter.Wait(ctx, &ec2.DescribeNatGatewaysInput{NatGatewayIds: []string{*vpcDetails.NATGateway.NatGatewayId}}, 5*time.Minute); err != nil {
+	for _, natGW := range natGWs {
+		for _, eipAllocation := range natGW.NatGatewayAddresses {
+			if eipAllocation.AllocationId == nil {
+				continue
+			}
+			if _, err := v.ec2Client.ReleaseAddress(ctx, &ec2.ReleaseAddressInput{AllocationId: eipAllocation.AllocationId}); err != nil {
+				// the EIP of a NAT gateway that was being deleted may have been released by an earlier delete
+				var apiErr smithy.APIError
+				if errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidAllocationID.NotFound" {
+					continue
+				}
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v Client) deleteNATInstance(ctx context.Context, vpcDetails *Details, opts DeleteOptions) error {
+	if _, err := v.ec2Client.TerminateInstances(ctx, &ec2.TerminateInstancesInput{InstanceIds: []string{*vpcDetails.NATInstance.InstanceId}}); err != nil {
 		return err
 	}
-	for _, eipAllocation := range vpcDetails.NATGateway.NatGatewayAddresses {
-		if _, err := v.ec2Client.ReleaseAddress(ctx, &ec2.ReleaseAddressInput{AllocationId: eipAllocation.AllocationId}); err != nil {
+	// The NAT instance's security group and subnet cannot be deleted until the instance is terminated
+	waiter := ec2.NewInstanceTerminatedWaiter(v.ec2Client, func(o *ec2.InstanceTerminatedWaiterOptions) {
+		o.Retryable = observeRetryable(v, "NAT Instance", o.Retryable, instanceStates)
+	})
+	if err := waiter.Wait(ctx, &ec2.DescribeInstancesInput{InstanceIds: []string{*vpcDetails.NATInstance.InstanceId}}, stepTimeout(opts.StepTimeout)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// deleteHostedZone deletes all records except the required SOA and NS records and then deletes the hosted zone
+func (v Client) deleteHostedZone(ctx context.Context, vpcDetails *Details, _ DeleteOptions) error {
+	recordSets, err := allPages(ctx, route53.NewListResourceRecordSetsPaginator(v.route53Client, &route53.ListResourceRecordSetsInput{HostedZoneId: vpcDetails.HostedZone.Id}),
+		func(page *route53.ListResourceRecordSetsOutput) []route53types.ResourceRecordSet {
+			return page.ResourceRecordSets
+		})
+	if err != nil {
+		return err
+	}
+	changes := lo.FilterMap(recordSets, func(recordSet route53types.ResourceRecordSet, _ int) (route53types.Change, bool) {
+		return route53types.Change{Action: route53types.ChangeActionDelete, ResourceRecordSet: &recordSet},
+			recordSet.Type != route53types.RRTypeSoa && recordSet.Type != route53types.RRTypeNs
+	})
+	if len(changes) != 0 {
+		if _, err := v.route53Client.ChangeResourceRecordSets(ctx, &route53.ChangeResourceRecordSetsInput{
+			HostedZoneId: vpcDetails.HostedZone.Id,
+			ChangeBatch:  &route53types.ChangeBatch{Changes: changes},
+		}); err != nil {
 			return err
 		}
 	}
+	if _, err := v.route53Client.DeleteHostedZone(ctx, &route53.DeleteHostedZoneInput{Id: vpcDetails.HostedZone.Id}); err != nil {
+		return err
+	}
+	return nil
+}
+
+// deleteDHCPOptions switches the VPC back to the default DHCP options and deletes the DHCP options set. It runs before
+// the VPC is deleted so that the set is not left behind when a later step fails.
+func (v Client) deleteDHCPOptions(ctx context.Context, vpcDetails *Details, _ DeleteOptions) error {
+	if _, err := v.ec2Client.AssociateDhcpOptions(ctx, &ec2.AssociateDhcpOptionsInput{DhcpOptionsId: aws.String("default"), VpcId: vpcDetails.VPC.VpcId}); err != nil {
+		return err
+	}
+	if _, err := v.ec2Client.DeleteDhcpOptions(ctx, &ec2.DeleteDhcpOptionsInput{DhcpOptionsId: vpcDetails.DHCPOptions.DhcpOptionsId}); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -78,6 +235,38 @@ func (v Client) deleteSubnets(ctx context.Context, vpcDetails *Details, _ Delete
 	return nil
 }
 
+func (v Client) deleteNetworkACLs(ctx context.Context, vpcDetails *Details, _ DeleteOptions) error {
+	// Subnet associations are removed when the subnets are deleted
+	for _, acl := range vpcDetails.NetworkACLs {
+		if _, err := v.ec2Client.DeleteNetworkAcl(ctx, &ec2.DeleteNetworkAclInput{NetworkAclId: acl.NetworkAclId}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v Client) deleteSecurityGroups(ctx context.Context, vpcDetails *Details, _ DeleteOptions) error {
+	// Revoke all rules first since security groups that reference each other cannot be deleted
+	for _, sg := range vpcDetails.SecurityGroups {
+		if len(sg.IpPermissions) != 0 {
+			if _, err := v.ec2Client.RevokeSecurityGroupIngress(ctx, &ec2.RevokeSecurityGroupIngressInput{GroupId: sg.GroupId, IpPermissions: sg.IpPermissions}); err != nil {
+				return err
+			}
+		}
+		if len(sg.IpPermissionsEgress) != 0 {
+			if _, err := v.ec2Client.RevokeSecurityGroupEgress(ctx, &ec2.RevokeSecurityGroupEgressInput{GroupId: sg.GroupId, IpPermissions: sg.IpPermissionsEgress}); err != nil {
+				return err
+			}
+		}
+	}
+	for _, sg := range vpcDetails.SecurityGroups {
+		if _, err := v.ec2Client.DeleteSecurityGroup(ctx, &ec2.DeleteSecurityGroupInput{GroupId: sg.GroupId}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (v Client) deleteVPC(ctx context.Context, vpcDetails *Details, _ DeleteOptions) error {
 	if _, err := v.ec2Client.DeleteVpc(ctx, &ec2.DeleteVpcInput{VpcId: vpcDetails.VPC.VpcId}); err != nil {
 		return err
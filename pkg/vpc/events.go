@@ -0,0 +1,281 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// Action is what the client does to a resource
+type Action string
+
+const (
+	ActionCreate    Action = "create"
+	ActionDelete    Action = "delete"
+	ActionConfigure Action = "configure"
+	ActionFetch     Action = "fetch"
+	ActionTag       Action = "tag"
+	ActionAccept    Action = "accept"
+	ActionRestrict  Action = "restrict"
+	ActionCheck     Action = "check"
+)
+
+// actionVerbs are the progressive and past forms of each action used to narrate events
+var actionVerbs = map[Action][2]string{
+	ActionCreate:    {"Creating", "Created"},
+	ActionDelete:    {"Deleting", "Deleted"},
+	ActionConfigure: {"Configuring", "Configured"},
+	ActionFetch:     {"Fetching", "Fetched"},
+	ActionTag:       {"Tagging", "Tagged"},
+	ActionAccept:    {"Accepting", "Accepted"},
+	ActionRestrict:  {"Restricting", "Restricted"},
+	ActionCheck:     {"Checking", "Checked"},
+}
+
+// Phase is the point in an action that an event reports
+type Phase string
+
+const (
+	// PhasePending events announce the steps an operation plans to run before any of them start
+	PhasePending   Phase = "pending"
+	PhaseStarted   Phase = "started"
+	PhaseSucceeded Phase = "succeeded"
+	PhaseFailed    Phase = "failed"
+	PhaseSkipped   Phase = "skipped"
+	// PhaseWaiting events report the state of resources that the started action is waiting on
+	PhaseWaiting Phase = "waiting"
+	// PhaseInfo events are notes that are not tied to the outcome of an action
+	PhaseInfo Phase = "info"
+)
+
+// Event reports progress of an operation on a VPC resource
+type Event struct {
+	Time     time.Time
+	Action   Action
+	Resource string
+	IDs      []string
+	Phase    Phase
+	// Duration is set on succeeded and failed events and is the time since the action started
+	Duration time.Duration
+	Err      error
+	Message  string
+}
+
+// Observer receives the events of a client's operations
+type Observer interface {
+	Observe(Event)
+}
+
+// ObserverFunc adapts a function to the Observer interface
+type ObserverFunc func(Event)
+
+func (f ObserverFunc) Observe(event Event) {
+	f(event)
+}
+
+// Observers sends each event to all of the observers in order
+type Observers []Observer
+
+func (o Observers) Observe(event Event) {
+	for _, observer := range o {
+		observer.Observe(event)
+	}
+}
+
+// LogObserver narrates events with the standard library logger, which is what a client does without an observer.
+// Pending events are not logged since each step is logged when it starts.
+var LogObserver = ObserverFunc(func(event Event) {
+	if event.Phase != PhasePending {
+		log.Print(event)
+	}
+})
+
+// DiscardObserver drops all events
+var DiscardObserver = ObserverFunc(func(Event) {})
+
+// WithObserver returns a copy of the client that sends events to the observer instead of the standard library logger
+func (v Client) WithObserver(observer Observer) *Client {
+	v.observer = observer
+	return &v
+}
+
+// Observer returns the observer that receives the client's events
+func (v Client) Observer() Observer {
+	if v.observer == nil {
+		return LogObserver
+	}
+	return v.observer
+}
+
+// String narrates the event, e.g. "Created NAT Gateway [nat-123] in 1m30s"
+func (e Event) String() string {
+	verbs, ok := actionVerbs[e.Action]
+	if !ok {
+		verbs = [2]string{string(e.Action), string(e.Action)}
+	}
+	var b strings.Builder
+	switch e.Phase {
+	case PhasePending:
+		b.WriteString(fmt.Sprintf("Pending %s %s", strings.ToLower(verbs[0]), e.Resource))
+	case PhaseWaiting:
+		b.WriteString(fmt.Sprintf("Waiting on %s", e.Resource))
+	case PhaseStarted:
+		b.WriteString(fmt.Sprintf("%s %s", verbs[0], e.Resource))
+	case PhaseSucceeded:
+		b.WriteString(fmt.Sprintf("%s %s", verbs[1], e.Resource))
+	case PhaseFailed:
+		b.WriteString(fmt.Sprintf("Failed %s %s", strings.ToLower(verbs[0]), e.Resource))
+	case PhaseSkipped:
+		b.WriteString(fmt.Sprintf("Skipping %s", e.Resource))
+	case PhaseInfo:
+		return e.Message
+	}
+	if len(e.IDs) != 0 {
+		b.WriteString(fmt.Sprintf(" %v", e.IDs))
+	}
+	if e.Message != "" {
+		b.WriteString(fmt.Sprintf(" (%s)", e.Message))
+	}
+	// sub-second durations are noise when narrating, they are still available to observers
+	if e.Duration >= time.Second {
+		b.WriteString(fmt.Sprintf(" in %s", e.Duration.Round(time.Second)))
+	}
+	if e.Err != nil {
+		b.WriteString(fmt.Sprintf(": %s", e.Err))
+	}
+	return b.String()
+}
+
+// eventJSON is the JSON encoding of an event with the error as a string and the duration in seconds
+type eventJSON struct {
+	Time     time.Time `json:"time"`
+	Action   Action    `json:"action,omitempty"`
+	Resource string    `json:"resource,omitempty"`
+	IDs      []string  `json:"ids,omitempty"`
+	Phase    Phase     `json:"phase"`
+	Duration float64   `json:"durationSeconds,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Message  string    `json:"message,omitempty"`
+}
+
+func (e Event) MarshalJSON() ([]byte, error) {
+	var errMessage string
+	if e.Err != nil {
+		errMessage = e.Err.Error()
+	}
+	return json.Marshal(eventJSON{e.Time, e.Action, e.Resource, e.IDs, e.Phase, e.Duration.Seconds(), errMessage, e.Message})
+}
+
+func (e *Event) UnmarshalJSON(data []byte) error {
+	var event eventJSON
+	if err := json.Unmarshal(data, &event); err != nil {
+		return err
+	}
+	*e = Event{
+		Time:     event.Time,
+		Action:   event.Action,
+		Resource: event.Resource,
+		IDs:      event.IDs,
+		Phase:    event.Phase,
+		Duration: time.Duration(event.Duration * float64(time.Second)),
+		Message:  event.Message,
+	}
+	if event.Error != "" {
+		e.Err = errors.New(event.Error)
+	}
+	return nil
+}
+
+func (v Client) emit(event Event) {
+	event.Time = time.Now()
+	v.Observer().Observe(event)
+}
+
+// tracker times an action and reports its outcome
+type tracker struct {
+	client   Client
+	action   Action
+	resource string
+	start    time.Time
+}
+
+// track emits a started event for the action and returns a tracker to report the outcome
+func (v Client) track(action Action, resource string, ids ...string) tracker {
+	v.emit(Event{Action: action, Resource: resource, IDs: ids, Phase: PhaseStarted})
+	return tracker{client: v, action: action, resource: resource, start: time.Now()}
+}
+
+// done emits a succeeded or failed event depending on err and returns err
+func (t tracker) done(err error, ids ...string) error {
+	t.client.emit(Event{
+		Action:   t.action,
+		Resource: t.resource,
+		IDs:      ids,
+		Phase:    lo.Ternary(err == nil, PhaseSucceeded, PhaseFailed),
+		Duration: time.Since(t.start),
+		Err:      err,
+	})
+	return err
+}
+
+// skipped emits a skipped event for a resource that is not needed
+func (v Client) skipped(action Action, resource string) {
+	v.emit(Event{Action: action, Resource: resource, Phase: PhaseSkipped})
+}
+
+// exists emits a skipped event for resources that a resumed operation keeps
+func (v Client) exists(action Action, resource string, ids ...string) {
+	v.emit(Event{Action: action, Resource: resource, IDs: ids, Phase: PhaseSkipped, Message: "already exists"})
+}
+
+// plan emits a pending event for each resource that the operation will act on
+func (v Client) plan(action Action, resources ...string) {
+	for _, resource := range resources {
+		v.emit(Event{Action: action, Resource: resource, Phase: PhasePending})
+	}
+}
+
+// waiting emits the states of resources by ID that the current action is waiting on
+func (v Client) waiting(resource string, states map[string]string) {
+	ids := lo.Keys(states)
+	sort.Strings(ids)
+	v.emit(Event{Resource: resource, IDs: ids, Phase: PhaseWaiting, Message: strings.Join(lo.Uniq(lo.Map(ids, func(id string, _ int) string { return states[id] })), ", ")})
+}
+
+// observeRetryable wraps an SDK waiter's retryable check to emit the states of the resources it is waiting on after each check
+func observeRetryable[Input any, Output any](v Client, resource string, retryable func(context.Context, *Input, *Output, error) (bool, error),
+	states func(*Output) map[string]string) func(context.Context, *Input, *Output, error) (bool, error) {
+	return func(ctx context.Context, input *Input, output *Output, err error) (bool, error) {
+		retry, err := retryable(ctx, input, output, err)
+		if retry && output != nil {
+			v.waiting(resource, states(output))
+		}
+		return retry, err
+	}
+}
+
+// info emits a note about the operation
+func (v Client) info(format string, args ...any) {
+	v.emit(Event{Phase: PhaseInfo, Message: fmt.Sprintf(format, args...)})
+}
@@ -0,0 +1,212 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/samber/lo"
+	"gopkg.in/yaml.v3"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+const (
+	// FormatterTemplatePrefix selects a Go text/template formatter, e.g. template={{.VPC.VpcId}}
+	FormatterTemplatePrefix = "template="
+	// FormatterJSONPathPrefix selects a JSONPath formatter, e.g. jsonpath={.Subnets[*].SubnetId}
+	FormatterJSONPathPrefix = "jsonpath="
+)
+
+// Formatter renders VPC Details in an output format
+type Formatter interface {
+	Name() string
+	Description() string
+	Format(*Details) ([]byte, error)
+}
+
+// FormatterFunc is a Formatter implemented by a function
+type FormatterFunc struct {
+	FormatterName        string
+	FormatterDescription string
+	FormatFunc           func(*Details) ([]byte, error)
+}
+
+func (f FormatterFunc) Name() string                            { return f.FormatterName }
+func (f FormatterFunc) Description() string                     { return f.FormatterDescription }
+func (f FormatterFunc) Format(details *Details) ([]byte, error) { return f.FormatFunc(details) }
+
+var (
+	formattersMu sync.RWMutex
+	formatters   = map[string]Formatter{}
+)
+
+func init() {
+	stringFormatter := func(output func(Details) (string, error)) func(*Details) ([]byte, error) {
+		return func(details *Details) ([]byte, error) {
+			out, err := output(*details)
+			return []byte(out), err
+		}
+	}
+	for _, formatter := range []Formatter{
+		FormatterFunc{"json", "JSON encoded VPC details", formatJSON},
+		FormatterFunc{"yaml", "YAML encoded VPC details", formatYAML},
+		FormatterFunc{"eksctl", "eksctl ClusterConfig vpc block", stringFormatter(Details.OutputEKSCTL)},
+		FormatterFunc{"terraform", "terraform.tfvars.json with the VPC resource IDs", stringFormatter(Details.OutputTerraform)},
+		FormatterFunc{"terraform-import", "terraform import blocks and resource skeletons", stringFormatter(Details.OutputTerraformImport)},
+		FormatterFunc{"karpenter", "Karpenter EC2NodeClass subnet and security group selector terms", stringFormatter(Details.OutputKarpenter)},
+		FormatterFunc{"capa", "Cluster API Provider AWS network spec", stringFormatter(Details.OutputCAPA)},
+		FormatterFunc{"cloudformation", "CloudFormation template in YAML that recreates the VPC", stringFormatter(Details.OutputCloudFormation)},
+		FormatterFunc{"cloudformation-json", "CloudFormation template in JSON that recreates the VPC", stringFormatter(Details.OutputCloudFormationJSON)},
+	} {
+		RegisterFormatter(formatter)
+	}
+}
+
+// RegisterFormatter adds a formatter to the registry, replacing any formatter with the same name
+func RegisterFormatter(formatter Formatter) {
+	formattersMu.Lock()
+	defer formattersMu.Unlock()
+	formatters[formatter.Name()] = formatter
+}
+
+// Formatters returns all registered formatters sorted by name
+func Formatters() []Formatter {
+	formattersMu.RLock()
+	defer formattersMu.RUnlock()
+	registered := lo.Values(formatters)
+	sort.Slice(registered, func(i, j int) bool { return registered[i].Name() < registered[j].Name() })
+	return registered
+}
+
+// GetFormatter returns the registered formatter with the name, or a template or JSONPath formatter
+// when the name is prefixed with template= or jsonpath=
+func GetFormatter(name string) (Formatter, error) {
+	switch {
+	case strings.HasPrefix(name, FormatterTemplatePrefix):
+		return newTemplateFormatter(strings.TrimPrefix(name, FormatterTemplatePrefix))
+	case strings.HasPrefix(name, FormatterJSONPathPrefix):
+		return newJSONPathFormatter(strings.TrimPrefix(name, FormatterJSONPathPrefix))
+	}
+	formattersMu.RLock()
+	formatter, ok := formatters[name]
+	formattersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown output format %q, supported formats are %s, %s<template> and %s<jsonpath>",
+			name, strings.Join(lo.Map(Formatters(), func(f Formatter, _ int) string { return f.Name() }), ", "), FormatterTemplatePrefix, FormatterJSONPathPrefix)
+	}
+	return formatter, nil
+}
+
+func formatJSON(details *Details) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(details); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// formatYAML encodes the details with the same keys as the JSON output
+func formatYAML(details *Details) ([]byte, error) {
+	jsonBytes, err := json.Marshal(details)
+	if err != nil {
+		return nil, err
+	}
+	// JSON is valid YAML, decoding it into a node preserves the key order
+	var node yaml.Node
+	if err := yaml.Unmarshal(jsonBytes, &node); err != nil {
+		return nil, err
+	}
+	blockStyle(&node)
+	out, err := encodeYAML(&node)
+	return []byte(out), err
+}
+
+// blockStyle clears the flow and quoting styles decoded from JSON so that the YAML is output in block style.
+// Scalars are still quoted by the encoder when needed to keep their type.
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// jsonData converts the details to generic JSON data so that templates and JSONPath expressions use the JSON keys
+func jsonData(details *Details) (any, error) {
+	jsonBytes, err := json.Marshal(details)
+	if err != nil {
+		return nil, err
+	}
+	var data any
+	if err := json.Unmarshal(jsonBytes, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func newTemplateFormatter(text string) (Formatter, error) {
+	tmpl, err := template.New("output").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing template %q: %w", text, err)
+	}
+	return FormatterFunc{
+		FormatterName:        FormatterTemplatePrefix + text,
+		FormatterDescription: "Go text/template",
+		FormatFunc: func(details *Details) ([]byte, error) {
+			data, err := jsonData(details)
+			if err != nil {
+				return nil, err
+			}
+			var b bytes.Buffer
+			if err := tmpl.Execute(&b, data); err != nil {
+				return nil, err
+			}
+			return b.Bytes(), nil
+		},
+	}, nil
+}
+
+func newJSONPathFormatter(expression string) (Formatter, error) {
+	// Like kubectl, allow expressions without the surrounding braces
+	if !strings.HasPrefix(expression, "{") {
+		expression = fmt.Sprintf("{%s}", expression)
+	}
+	jp := jsonpath.New("output").AllowMissingKeys(true)
+	if err := jp.Parse(expression); err != nil {
+		return nil, fmt.Errorf("parsing jsonpath %q: %w", expression, err)
+	}
+	return FormatterFunc{
+		FormatterName:        FormatterJSONPathPrefix + expression,
+		FormatterDescription: "JSONPath expression",
+		FormatFunc: func(details *Details) ([]byte, error) {
+			data, err := jsonData(details)
+			if err != nil {
+				return nil, err
+			}
+			var b bytes.Buffer
+			if err := jp.Execute(&b, data); err != nil {
+				return nil, err
+			}
+			return b.Bytes(), nil
+		},
+	}, nil
+}
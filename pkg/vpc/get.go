@@ -16,114 +16,374 @@ package vpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/aws/smithy-go"
 	"github.com/samber/lo"
 )
 
+// ErrNotFound is returned when no VPC matches the options
+var ErrNotFound = errors.New("not found")
+
 func (v Client) getVPC(ctx context.Context, opts GetOptions) (*types.Vpc, error) {
-	vpcOut, err := v.ec2Client.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{
-		Filters: []types.Filter{
+	if opts.Name == "" && opts.ID == "" && len(opts.Tags) == 0 {
+		return nil, fmt.Errorf("a VPC name, ID or tag selector is required")
+	}
+	// EC2 tag filters treat * and ? as wildcards, which would select any one of the matching VPCs
+	if IsGlob(opts.Name) {
+		return nil, fmt.Errorf("VPC name %s is a glob but a single VPC is expected, list the matching VPCs and select one by name or ID", opts.Name)
+	}
+	tags := lo.Assign(opts.Tags)
+	if opts.Name != "" {
+		tags["Name"] = opts.Name
+	}
+	var vpcs []types.Vpc
+	var err error
+	if len(opts.ResourceIDs) != 0 {
+		// the tags of VPCs selected by resource IDs may have been lost
+		vpcs, err = allPages(ctx, ec2.NewDescribeVpcsPaginator(v.ec2Client, &ec2.DescribeVpcsInput{Filters: ownedFilters(opts, "vpc-id")}),
+			func(page *ec2.DescribeVpcsOutput) []types.Vpc { return page.Vpcs })
+	} else {
+		vpcs, err = v.findVPCs(ctx, opts.ID, tags)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch len(vpcs) {
+	case 0:
+		return nil, fmt.Errorf("VPC %s %w", opts, ErrNotFound)
+	case 1:
+		return &vpcs[0], nil
+	default:
+		return nil, fmt.Errorf("VPC %s is ambiguous, select one of the matching VPCs by ID: %s", opts, strings.Join(lo.Map(vpcs, func(vpc types.Vpc, _ int) string {
+			return fmt.Sprintf("%s (%s)", *vpc.VpcId, lo.FromPtr(nameTag(vpc.Tags)))
+		}), ", "))
+	}
+}
+
+// findVPCs returns the VPCs created by vpcctl with the ID, when set, and all of the tags
+func (v Client) findVPCs(ctx context.Context, id string, tags map[string]string) ([]types.Vpc, error) {
+	filters := []types.Filter{
+		{
+			Name:   aws.String(fmt.Sprintf("tag:%s", CreatedByTagKey)),
+			Values: []string{CreatedByTagValue},
+		},
+	}
+	if id != "" {
+		filters = append(filters, types.Filter{Name: aws.String("vpc-id"), Values: []string{id}})
+	}
+	for _, key := range lo.Keys(tags) {
+		filters = append(filters, types.Filter{Name: aws.String(fmt.Sprintf("tag:%s", key)), Values: []string{tags[key]}})
+	}
+	return allPages(ctx, ec2.NewDescribeVpcsPaginator(v.ec2Client, &ec2.DescribeVpcsInput{Filters: filters}), func(page *ec2.DescribeVpcsOutput) []types.Vpc { return page.Vpcs })
+}
+
+// ownedFilters selects the resources created by vpcctl by their tags. When the options have resource IDs, e.g. from a journal,
+// the resources are selected by the idFilter instead since their tags may have been lost.
+func ownedFilters(opts GetOptions, idFilter string, tagFilters ...types.Filter) []types.Filter {
+	if len(opts.ResourceIDs) != 0 {
+		return []types.Filter{{Name: aws.String(idFilter), Values: opts.ResourceIDs}}
+	}
+	return append(tagFilters, types.Filter{
+		Name:   aws.String(fmt.Sprintf("tag:%s", CreatedByTagKey)),
+		Values: []string{CreatedByTagValue},
+	})
+}
+
+// formatSelector formats tags as a label selector, e.g. env=ci,team=infra
+func formatSelector(tags map[string]string) string {
+	selector := lo.MapToSlice(tags, func(key string, value string) string { return fmt.Sprintf("%s=%s", key, value) })
+	sort.Strings(selector)
+	return strings.Join(selector, ",")
+}
+
+func (v Client) getSubnets(ctx context.Context, vpcID string, opts GetOptions) ([]*types.Subnet, error) {
+	subnets, err := allPages(ctx, ec2.NewDescribeSubnetsPaginator(v.ec2Client, &ec2.DescribeSubnetsInput{
+		Filters: append([]types.Filter{
 			{
-				Name:   aws.String("tag:Name"),
-				Values: []string{opts.Name},
+				Name:   aws.String("vpc-id"),
+				Values: []string{vpcID},
 			},
+		}, ownedFilters(opts, "subnet-id")...),
+	}), func(page *ec2.DescribeSubnetsOutput) []types.Subnet { return page.Subnets })
+	if err != nil {
+		return nil, err
+	}
+	return lo.Map(subnets, func(subnet types.Subnet, _ int) *types.Subnet { return &subnet }), nil
+}
+
+func (v Client) getRouteTables(ctx context.Context, vpcID string, opts GetOptions) ([]*types.RouteTable, error) {
+	routeTables, err := allPages(ctx, ec2.NewDescribeRouteTablesPaginator(v.ec2Client, &ec2.DescribeRouteTablesInput{
+		Filters: append([]types.Filter{
 			{
-				Name:   aws.String(fmt.Sprintf("tag:%s", CreatedByTagKey)),
-				Values: []string{CreatedByTagValue},
+				Name:   aws.String("vpc-id"),
+				Values: []string{vpcID},
 			},
-		},
-	})
+		}, ownedFilters(opts, "route-table-id")...),
+	}), func(page *ec2.DescribeRouteTablesOutput) []types.RouteTable { return page.RouteTables })
 	if err != nil {
 		return nil, err
 	}
-	if len(vpcOut.Vpcs) == 0 {
-		return nil, fmt.Errorf("VPC %s not found", opts.Name)
+	return lo.Map(routeTables, func(rt types.RouteTable, _ int) *types.RouteTable { return &rt }), nil
+}
+
+func (v Client) getIGW(ctx context.Context, vpcID string, opts GetOptions) (*types.InternetGateway, error) {
+	igws, err := allPages(ctx, ec2.NewDescribeInternetGatewaysPaginator(v.ec2Client, &ec2.DescribeInternetGatewaysInput{
+		Filters: append([]types.Filter{
+			{
+				Name:   aws.String("attachment.vpc-id"),
+				Values: []string{vpcID},
+			},
+		}, ownedFilters(opts, "internet-gateway-id")...),
+	}), func(page *ec2.DescribeInternetGatewaysOutput) []types.InternetGateway { return page.InternetGateways })
+	if err != nil {
+		return nil, err
+	}
+	if len(igws) == 0 {
+		return nil, nil
 	}
-	return &vpcOut.Vpcs[0], nil
+	return &igws[0], nil
 }
 
-func (v Client) getSubnets(ctx context.Context, vpcID string, _ GetOptions) ([]*types.Subnet, error) {
-	subnetsOut, err := v.ec2Client.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{
-		Filters: []types.Filter{
+// getNATGWs returns the NAT gateways that are not deleted yet, including failed ones
+func (v Client) getNATGWs(ctx context.Context, vpcID string, opts GetOptions) ([]*types.NatGateway, error) {
+	natGWs, err := allPages(ctx, ec2.NewDescribeNatGatewaysPaginator(v.ec2Client, &ec2.DescribeNatGatewaysInput{
+		Filter: append([]types.Filter{
 			{
 				Name:   aws.String("vpc-id"),
 				Values: []string{vpcID},
 			},
 			{
-				Name:   aws.String(fmt.Sprintf("tag:%s", CreatedByTagKey)),
-				Values: []string{CreatedByTagValue},
+				Name: aws.String("state"),
+				Values: []string{
+					string(types.NatGatewayStatePending),
+					string(types.NatGatewayStateAvailable),
+					string(types.NatGatewayStateFailed),
+					string(types.NatGatewayStateDeleting),
+				},
 			},
-		},
-	})
+		}, ownedFilters(opts, "nat-gateway-id")...),
+	}), func(page *ec2.DescribeNatGatewaysOutput) []types.NatGateway { return page.NatGateways })
 	if err != nil {
 		return nil, err
 	}
-	return lo.Map(subnetsOut.Subnets, func(subnet types.Subnet, _ int) *types.Subnet { return &subnet }), nil
+	return lo.Map(natGWs, func(natGW types.NatGateway, _ int) *types.NatGateway { return &natGW }), nil
 }
 
-func (v Client) getRouteTables(ctx context.Context, vpcID string, _ GetOptions) ([]*types.RouteTable, error) {
-	routeTablesOut, err := v.ec2Client.DescribeRouteTables(ctx, &ec2.DescribeRouteTablesInput{
-		Filters: []types.Filter{
+func (v Client) getSecurityGroups(ctx context.Context, vpcID string, opts GetOptions) ([]*types.SecurityGroup, error) {
+	sgs, err := allPages(ctx, ec2.NewDescribeSecurityGroupsPaginator(v.ec2Client, &ec2.DescribeSecurityGroupsInput{
+		Filters: append([]types.Filter{
 			{
 				Name:   aws.String("vpc-id"),
 				Values: []string{vpcID},
 			},
+		}, ownedFilters(opts, "group-id")...),
+	}), func(page *ec2.DescribeSecurityGroupsOutput) []types.SecurityGroup { return page.SecurityGroups })
+	if err != nil {
+		return nil, err
+	}
+	return lo.Map(sgs, func(sg types.SecurityGroup, _ int) *types.SecurityGroup { return &sg }), nil
+}
+
+func (v Client) getNetworkACLs(ctx context.Context, vpcID string, opts GetOptions) ([]*types.NetworkAcl, error) {
+	acls, err := allPages(ctx, ec2.NewDescribeNetworkAclsPaginator(v.ec2Client, &ec2.DescribeNetworkAclsInput{
+		Filters: append([]types.Filter{
 			{
-				Name:   aws.String(fmt.Sprintf("tag:%s", CreatedByTagKey)),
-				Values: []string{CreatedByTagValue},
+				Name:   aws.String("vpc-id"),
+				Values: []string{vpcID},
 			},
-		},
-	})
+		}, ownedFilters(opts, "network-acl-id")...),
+	}), func(page *ec2.DescribeNetworkAclsOutput) []types.NetworkAcl { return page.NetworkAcls })
 	if err != nil {
 		return nil, err
 	}
-	return lo.Map(routeTablesOut.RouteTables, func(rt types.RouteTable, _ int) *types.RouteTable { return &rt }), nil
+	return lo.Map(acls, func(acl types.NetworkAcl, _ int) *types.NetworkAcl { return &acl }), nil
+}
+
+// getPeeringConnections returns the live peering connections that vpcctl created on either side of the VPC
+func (v Client) getPeeringConnections(ctx context.Context, vpcID string, opts GetOptions) ([]*types.VpcPeeringConnection, error) {
+	var peeringConnections []*types.VpcPeeringConnection
+	// filters are ANDed so the requester and accepter side need to be looked up separately
+	for _, vpcFilter := range []string{"requester-vpc-info.vpc-id", "accepter-vpc-info.vpc-id"} {
+		pcxs, err := allPages(ctx, ec2.NewDescribeVpcPeeringConnectionsPaginator(v.ec2Client, &ec2.DescribeVpcPeeringConnectionsInput{
+			Filters: append([]types.Filter{
+				{
+					Name:   aws.String(vpcFilter),
+					Values: []string{vpcID},
+				},
+				{
+					Name:   aws.String("status-code"),
+					Values: livePeeringStates,
+				},
+			}, ownedFilters(opts, "vpc-peering-connection-id")...),
+		}), func(page *ec2.DescribeVpcPeeringConnectionsOutput) []types.VpcPeeringConnection {
+			return page.VpcPeeringConnections
+		})
+		if err != nil {
+			return nil, err
+		}
+		peeringConnections = append(peeringConnections, lo.Map(pcxs, func(pcx types.VpcPeeringConnection, _ int) *types.VpcPeeringConnection { return &pcx })...)
+	}
+	return peeringConnections, nil
 }
 
-func (v Client) getIGW(ctx context.Context, vpcID string, _ GetOptions) (*types.InternetGateway, error) {
-	igwOut, err := v.ec2Client.DescribeInternetGateways(ctx, &ec2.DescribeInternetGatewaysInput{
-		Filters: []types.Filter{
+func (v Client) getTGWAttachment(ctx context.Context, vpcID string, opts GetOptions) (*types.TransitGatewayVpcAttachment, error) {
+	attachments, err := allPages(ctx, ec2.NewDescribeTransitGatewayVpcAttachmentsPaginator(v.ec2Client, &ec2.DescribeTransitGatewayVpcAttachmentsInput{
+		Filters: append([]types.Filter{
 			{
-				Name:   aws.String("attachment.vpc-id"),
+				Name:   aws.String("vpc-id"),
 				Values: []string{vpcID},
 			},
 			{
-				Name:   aws.String(fmt.Sprintf("tag:%s", CreatedByTagKey)),
-				Values: []string{CreatedByTagValue},
+				Name: aws.String("state"),
+				Values: []string{
+					string(types.TransitGatewayAttachmentStatePending),
+					string(types.TransitGatewayAttachmentStatePendingAcceptance),
+					string(types.TransitGatewayAttachmentStateAvailable),
+					string(types.TransitGatewayAttachmentStateModifying),
+				},
 			},
-		},
+		}, ownedFilters(opts, "transit-gateway-attachment-id")...),
+	}), func(page *ec2.DescribeTransitGatewayVpcAttachmentsOutput) []types.TransitGatewayVpcAttachment {
+		return page.TransitGatewayVpcAttachments
 	})
 	if err != nil {
 		return nil, err
 	}
-	if len(igwOut.InternetGateways) == 0 {
+	if len(attachments) == 0 {
 		return nil, nil
 	}
-	return &igwOut.InternetGateways[0], nil
+	return &attachments[0], nil
 }
 
-func (v Client) getNATGW(ctx context.Context, vpcID string, _ GetOptions) (*types.NatGateway, error) {
-	natGWOut, err := v.ec2Client.DescribeNatGateways(ctx, &ec2.DescribeNatGatewaysInput{
-		Filter: []types.Filter{
+func (v Client) getNATInstance(ctx context.Context, vpcID string, opts GetOptions) (*types.Instance, error) {
+	instances, err := allPages(ctx, ec2.NewDescribeInstancesPaginator(v.ec2Client, &ec2.DescribeInstancesInput{
+		Filters: append([]types.Filter{
 			{
 				Name:   aws.String("vpc-id"),
 				Values: []string{vpcID},
 			},
 			{
-				Name:   aws.String(fmt.Sprintf("tag:%s", CreatedByTagKey)),
-				Values: []string{CreatedByTagValue},
+				Name: aws.String("instance-state-name"),
+				Values: []string{
+					string(types.InstanceStateNamePending),
+					string(types.InstanceStateNameRunning),
+					string(types.InstanceStateNameStopping),
+					string(types.InstanceStateNameStopped),
+				},
 			},
-		},
+		}, ownedFilters(opts, "instance-id", types.Filter{
+			Name:   aws.String(fmt.Sprintf("tag:%s", NATInstanceTagKey)),
+			Values: []string{"true"},
+		})...),
+	}), func(page *ec2.DescribeInstancesOutput) []types.Instance {
+		return lo.FlatMap(page.Reservations, func(reservation types.Reservation, _ int) []types.Instance { return reservation.Instances })
 	})
 	if err != nil {
 		return nil, err
 	}
-	if len(natGWOut.NatGateways) == 0 {
+	if len(instances) == 0 {
 		return nil, nil
 	}
-	return &natGWOut.NatGateways[0], nil
+	return &instances[0], nil
+}
+
+// getDNS looks up the VPC DNS attributes, the DHCP options set and the private hosted zone created by vpcctl
+func (v Client) getDNS(ctx context.Context, vpcDetails *Details, opts GetOptions) error {
+	vpcID := vpcDetails.VPC.VpcId
+	dnsSupportOut, err := v.ec2Client.DescribeVpcAttribute(ctx, &ec2.DescribeVpcAttributeInput{VpcId: vpcID, Attribute: types.VpcAttributeNameEnableDnsSupport})
+	if err != nil {
+		return err
+	}
+	vpcDetails.EnableDNSSupport = lo.FromPtr(dnsSupportOut.EnableDnsSupport.Value)
+	dnsHostnamesOut, err := v.ec2Client.DescribeVpcAttribute(ctx, &ec2.DescribeVpcAttributeInput{VpcId: vpcID, Attribute: types.VpcAttributeNameEnableDnsHostnames})
+	if err != nil {
+		return err
+	}
+	vpcDetails.EnableDNSHostnames = lo.FromPtr(dnsHostnamesOut.EnableDnsHostnames.Value)
+
+	if vpcDetails.VPC.DhcpOptionsId != nil && *vpcDetails.VPC.DhcpOptionsId != "default" {
+		dhcpOptions, err := allPages(ctx, ec2.NewDescribeDhcpOptionsPaginator(v.ec2Client, &ec2.DescribeDhcpOptionsInput{
+			DhcpOptionsIds: []string{*vpcDetails.VPC.DhcpOptionsId},
+			Filters:        ownedFilters(opts, "dhcp-options-id"),
+		}), func(page *ec2.DescribeDhcpOptionsOutput) []types.DhcpOptions { return page.DhcpOptions })
+		if err != nil {
+			return err
+		}
+		if len(dhcpOptions) != 0 {
+			vpcDetails.DHCPOptions = &dhcpOptions[0]
+		}
+	}
+
+	hostedZones, err := listHostedZonesByVPC(ctx, v.route53Client, *vpcID, v.cfg.Region)
+	// credentials without Route 53 permissions can still manage VPCs that have no private hosted zone
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && lo.Contains([]string{"AccessDenied", "AccessDeniedException"}, apiErr.ErrorCode()) {
+		v.info("Unable to look up the private hosted zone of %s, assuming it has none: %s", *vpcID, apiErr.ErrorMessage())
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, hostedZone := range hostedZones {
+		tagsOut, err := v.route53Client.ListTagsForResource(ctx, &route53.ListTagsForResourceInput{
+			ResourceId:   hostedZone.HostedZoneId,
+			ResourceType: route53types.TagResourceTypeHostedzone,
+		})
+		if err != nil {
+			return err
+		}
+		owned := lo.ContainsBy(tagsOut.ResourceTagSet.Tags, func(tag route53types.Tag) bool {
+			return *tag.Key == CreatedByTagKey && *tag.Value == CreatedByTagValue
+		})
+		if len(opts.ResourceIDs) != 0 {
+			// hosted zone IDs are returned with and without the /hostedzone/ prefix
+			owned = lo.ContainsBy(opts.ResourceIDs, func(id string) bool {
+				return strings.TrimPrefix(id, "/hostedzone/") == strings.TrimPrefix(*hostedZone.HostedZoneId, "/hostedzone/")
+			})
+		}
+		if !owned {
+			continue
+		}
+		hostedZoneOut, err := v.route53Client.GetHostedZone(ctx, &route53.GetHostedZoneInput{Id: hostedZone.HostedZoneId})
+		if err != nil {
+			return err
+		}
+		vpcDetails.HostedZone = hostedZoneOut.HostedZone
+		break
+	}
+	return nil
+}
+
+// hostedZonesByVPCLister is the part of the Route 53 client that lists the hosted zones of a VPC
+type hostedZonesByVPCLister interface {
+	ListHostedZonesByVPC(context.Context, *route53.ListHostedZonesByVPCInput, ...func(*route53.Options)) (*route53.ListHostedZonesByVPCOutput, error)
+}
+
+// listHostedZonesByVPC lists all pages of the hosted zones associated with the VPC since the SDK has no paginator for it
+func listHostedZonesByVPC(ctx context.Context, client hostedZonesByVPCLister, vpcID string, region string) ([]route53types.HostedZoneSummary, error) {
+	var hostedZones []route53types.HostedZoneSummary
+	input := &route53.ListHostedZonesByVPCInput{
+		VPCId:     aws.String(vpcID),
+		VPCRegion: route53types.VPCRegion(region),
+	}
+	for {
+		hostedZonesOut, err := client.ListHostedZonesByVPC(ctx, input)
+		if err != nil {
+			return hostedZones, err
+		}
+		hostedZones = append(hostedZones, hostedZonesOut.HostedZoneSummaries...)
+		if hostedZonesOut.NextToken == nil {
+			return hostedZones, nil
+		}
+		input.NextToken = hostedZonesOut.NextToken
+	}
 }
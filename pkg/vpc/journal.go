@@ -0,0 +1,240 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/samber/lo"
+)
+
+const (
+	OperationCreate = "create"
+	OperationDelete = "delete"
+
+	OutcomeRunning   = "running"
+	OutcomeSucceeded = "succeeded"
+	OutcomeFailed    = "failed"
+)
+
+// Journal is the record of the operations run on a VPC. It is saved as each step of an operation completes,
+// so the resources that were created are known even when the operation is interrupted or their tags are lost.
+type Journal struct {
+	Name       string              `json:"name"`
+	Account    string              `json:"account"`
+	Region     string              `json:"region"`
+	Operations []*JournalOperation `json:"operations"`
+
+	path string
+	mu   sync.Mutex
+	// err is the first error saving the journal while recording events, which are not able to return errors
+	err error
+}
+
+// JournalOperation is an operation run on a VPC
+type JournalOperation struct {
+	Operation string          `json:"operation"`
+	Options   json.RawMessage `json:"options,omitempty"`
+	Started   time.Time       `json:"started"`
+	Finished  *time.Time      `json:"finished,omitempty"`
+	// Outcome is OutcomeRunning until the operation finishes, it stays running when vpcctl was killed
+	Outcome string `json:"outcome"`
+	Error   string `json:"error,omitempty"`
+	// Events are the succeeded, failed and skipped steps and the notes of the operation
+	Events []Event `json:"events"`
+	// ResourceIDs are the IDs of the VPC's resources when the operation finished
+	ResourceIDs []string `json:"resourceIDs,omitempty"`
+}
+
+// JournalPath returns the path of a VPC's journal in the state directory, <dir>/<account>/<region>/<name>.json
+func JournalPath(dir string, account string, region string, name string) string {
+	return filepath.Join(dir, account, region, fmt.Sprintf("%s.json", name))
+}
+
+// AccountID returns the AWS account ID of the client's credentials
+func (v Client) AccountID(ctx context.Context) (string, error) {
+	identity, err := sts.NewFromConfig(v.cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+	return *identity.Account, nil
+}
+
+// OpenJournal loads the journal of the VPC named name in the client's account and region from the state directory.
+// The journal is empty when it does not exist yet and it is created when the first operation is recorded.
+func (v Client) OpenJournal(ctx context.Context, dir string, name string) (*Journal, error) {
+	account, err := v.AccountID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	path := JournalPath(dir, account, v.cfg.Region, name)
+	journal, err := LoadJournal(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Journal{Name: name, Account: account, Region: v.cfg.Region, path: path}, nil
+	}
+	return journal, err
+}
+
+// LoadJournal reads a journal file
+func LoadJournal(path string) (*Journal, error) {
+	journalBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	journal := &Journal{path: path}
+	if err := json.Unmarshal(journalBytes, journal); err != nil {
+		return nil, fmt.Errorf("invalid journal %s: %w", path, err)
+	}
+	return journal, nil
+}
+
+// ListJournals loads the journals of all accounts and regions in the state directory
+func ListJournals(dir string) ([]*Journal, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*", "*", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var journals []*Journal
+	for _, path := range paths {
+		journal, err := LoadJournal(path)
+		if err != nil {
+			return nil, err
+		}
+		journals = append(journals, journal)
+	}
+	return journals, nil
+}
+
+// Record starts recording an operation with its options and returns a client that also sends its events to the journal
+func (j *Journal) Record(v *Client, operation string, options any) (*Client, error) {
+	optionsJSON, err := json.Marshal(options)
+	if err != nil {
+		return nil, err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Operations = append(j.Operations, &JournalOperation{Operation: operation, Options: optionsJSON, Started: time.Now(), Outcome: OutcomeRunning})
+	if err := j.save(); err != nil {
+		return nil, err
+	}
+	return v.WithObserver(Observers{v.Observer(), j}), nil
+}
+
+// Observe records the outcome of each step of the current operation and saves the journal
+func (j *Journal) Observe(event Event) {
+	if event.Phase == PhasePending || event.Phase == PhaseStarted || event.Phase == PhaseWaiting {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if len(j.Operations) == 0 {
+		return
+	}
+	operation := j.Operations[len(j.Operations)-1]
+	operation.Events = append(operation.Events, event)
+	if err := j.save(); err != nil && j.err == nil {
+		j.err = err
+	}
+}
+
+// Finish records the outcome of the current operation and the IDs of the VPC's resources and saves the journal
+func (j *Journal) Finish(vpcDetails *Details, err error) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if len(j.Operations) == 0 {
+		return fmt.Errorf("no operation is being recorded in the journal of %s", j.Name)
+	}
+	operation := j.Operations[len(j.Operations)-1]
+	operation.Finished = lo.ToPtr(time.Now())
+	operation.Outcome = lo.Ternary(err == nil, OutcomeSucceeded, OutcomeFailed)
+	if err != nil {
+		operation.Error = err.Error()
+	}
+	if vpcDetails != nil {
+		operation.ResourceIDs = vpcDetails.ResourceIDs()
+	}
+	if err := j.save(); err != nil {
+		return err
+	}
+	return j.err
+}
+
+// ResourceIDs returns the IDs of the resources that the journaled operations created and did not delete
+func (j *Journal) ResourceIDs() []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var ids []string
+	for _, operation := range j.Operations {
+		if operation.Operation == OperationCreate {
+			ids = append(ids, operation.ResourceIDs...)
+		}
+		for _, event := range operation.Events {
+			switch {
+			// a failed step reports the IDs of the resources it created before it failed
+			case event.Action == ActionCreate && (event.Phase == PhaseSucceeded || event.Phase == PhaseFailed):
+				ids = append(ids, event.IDs...)
+			case event.Action == ActionDelete && event.Phase == PhaseSucceeded:
+				ids = lo.Without(ids, event.IDs...)
+			}
+		}
+	}
+	return lo.Uniq(ids)
+}
+
+// save writes the journal to a temporary file that replaces the journal so that it is never partially written
+func (j *Journal) save() error {
+	journalBytes, err := json.MarshalIndent(j, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0o755); err != nil {
+		return err
+	}
+	tmpPath := j.path + ".tmp"
+	if err := os.WriteFile(tmpPath, journalBytes, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, j.path)
+}
+
+// ResourceIDs returns the IDs of the VPC and its resources that vpcctl manages
+func (d Details) ResourceIDs() []string {
+	ids := lo.Flatten([][]string{
+		optionalID(d.VPC, func(vpc *types.Vpc) *string { return vpc.VpcId }),
+		lo.Map(d.Subnets, func(subnet *types.Subnet, _ int) string { return *subnet.SubnetId }),
+		lo.Map(d.RouteTables, func(rt *types.RouteTable, _ int) string { return *rt.RouteTableId }),
+		optionalID(d.InternetGateway, func(igw *types.InternetGateway) *string { return igw.InternetGatewayId }),
+		lo.Map(allNATGWs(&d), func(natGW *types.NatGateway, _ int) string { return *natGW.NatGatewayId }),
+		optionalID(d.NATInstance, func(instance *types.Instance) *string { return instance.InstanceId }),
+		lo.Map(d.SecurityGroups, func(sg *types.SecurityGroup, _ int) string { return *sg.GroupId }),
+		lo.Map(d.NetworkACLs, func(acl *types.NetworkAcl, _ int) string { return *acl.NetworkAclId }),
+		optionalID(d.TransitGatewayAttachment, func(a *types.TransitGatewayVpcAttachment) *string { return a.TransitGatewayAttachmentId }),
+		optionalID(d.DHCPOptions, func(dhcp *types.DhcpOptions) *string { return dhcp.DhcpOptionsId }),
+	})
+	if d.HostedZone != nil {
+		ids = append(ids, *d.HostedZone.Id)
+	}
+	return ids
+}
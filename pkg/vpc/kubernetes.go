@@ -0,0 +1,125 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vpc
+
+import (
+	"bytes"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/samber/lo"
+	"gopkg.in/yaml.v3"
+)
+
+// OutputKarpenter outputs the subnet and security group selector terms for a Karpenter EC2NodeClass spec.
+// Private subnets are selected, or public subnets if the VPC does not have any private subnets.
+func (d Details) OutputKarpenter() (string, error) {
+	// subnetSelectorTerms:
+	//   - id: subnet-0ff156e0c4a6d300c
+	//   - id: subnet-0426fb4a607393184
+	// securityGroupSelectorTerms:
+	//   - id: sg-0153e560b3129a696
+	type selectorTerm struct {
+		ID string `yaml:"id"`
+	}
+	type karpenterSelectors struct {
+		SubnetSelectorTerms        []selectorTerm `yaml:"subnetSelectorTerms"`
+		SecurityGroupSelectorTerms []selectorTerm `yaml:"securityGroupSelectorTerms,omitempty"`
+	}
+
+	subnets := lo.Filter(d.Subnets, func(subnet *types.Subnet, _ int) bool { return SubnetType(subnet) == SubnetTypePrivate })
+	if len(subnets) == 0 {
+		subnets = lo.Filter(d.Subnets, func(subnet *types.Subnet, _ int) bool { return SubnetType(subnet) == SubnetTypePublic })
+	}
+	// The NAT instance's security group only allows traffic from the VPC to the NAT instance
+	securityGroups := lo.Reject(d.SecurityGroups, func(sg *types.SecurityGroup, _ int) bool {
+		return d.NATInstance != nil && lo.ContainsBy(d.NATInstance.SecurityGroups, func(group types.GroupIdentifier) bool { return *group.GroupId == *sg.GroupId })
+	})
+	return encodeYAML(karpenterSelectors{
+		SubnetSelectorTerms: lo.Map(subnets, func(subnet *types.Subnet, _ int) selectorTerm { return selectorTerm{ID: *subnet.SubnetId} }),
+		SecurityGroupSelectorTerms: lo.Map(securityGroups, func(sg *types.SecurityGroup, _ int) selectorTerm {
+			return selectorTerm{ID: *sg.GroupId}
+		}),
+	})
+}
+
+// OutputCAPA outputs the network spec for a Cluster API Provider AWS AWSCluster or AWSManagedControlPlane
+func (d Details) OutputCAPA() (string, error) {
+	// network:
+	//   vpc:
+	//     id: vpc-11111
+	//     cidrBlock: 10.0.0.0/16
+	//   subnets:
+	//     - id: subnet-0ff156e0c4a6d300c
+	//       availabilityZone: us-west-2a
+	//       cidrBlock: 10.0.0.0/18
+	//       isPublic: false
+	type capaVPC struct {
+		ID                string `yaml:"id"`
+		CIDRBlock         string `yaml:"cidrBlock"`
+		InternetGatewayID string `yaml:"internetGatewayId,omitempty"`
+	}
+	type capaSubnet struct {
+		ID               string `yaml:"id"`
+		AvailabilityZone string `yaml:"availabilityZone"`
+		CIDRBlock        string `yaml:"cidrBlock"`
+		IsPublic         bool   `yaml:"isPublic"`
+		NATGatewayID     string `yaml:"natGatewayId,omitempty"`
+	}
+	type capaNetwork struct {
+		VPC     capaVPC      `yaml:"vpc"`
+		Subnets []capaSubnet `yaml:"subnets"`
+	}
+	type capaSpec struct {
+		Network capaNetwork `yaml:"network"`
+	}
+
+	network := capaNetwork{
+		VPC: capaVPC{
+			ID:        *d.VPC.VpcId,
+			CIDRBlock: *d.VPC.CidrBlock,
+		},
+	}
+	if d.InternetGateway != nil {
+		network.VPC.InternetGatewayID = *d.InternetGateway.InternetGatewayId
+	}
+	for _, subnet := range d.Subnets {
+		subnetType := SubnetType(subnet)
+		if subnetType == SubnetTypeTransitGateway {
+			continue
+		}
+		capaSubnet := capaSubnet{
+			ID:               *subnet.SubnetId,
+			AvailabilityZone: *subnet.AvailabilityZone,
+			CIDRBlock:        *subnet.CidrBlock,
+			IsPublic:         subnetType == SubnetTypePublic,
+		}
+		// CAPA expects the NAT gateway on the public subnet it is placed in
+		if natGW, ok := lo.Find(d.NATGateways, func(natGW *types.NatGateway) bool { return lo.FromPtr(natGW.SubnetId) == *subnet.SubnetId }); ok {
+			capaSubnet.NATGatewayID = *natGW.NatGatewayId
+		}
+		network.Subnets = append(network.Subnets, capaSubnet)
+	}
+	return encodeYAML(capaSpec{Network: network})
+}
+
+func encodeYAML(data any) (string, error) {
+	var b bytes.Buffer
+	yamlEncoder := yaml.NewEncoder(&b)
+	yamlEncoder.SetIndent(2)
+	if err := yamlEncoder.Encode(data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,329 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/samber/lo"
+)
+
+// fakePages returns items in pages of pageSize with the index of the next item as the next token
+type fakePages[T any] struct {
+	items    []T
+	pageSize int
+	// failAt fails the call for the page that starts at the index when it is not 0
+	failAt int
+	calls  int
+}
+
+func (f *fakePages[T]) page(token *string) ([]T, *string, error) {
+	f.calls++
+	start := 0
+	if token != nil {
+		var err error
+		if start, err = strconv.Atoi(*token); err != nil {
+			return nil, nil, fmt.Errorf("invalid next token %q", *token)
+		}
+	}
+	if f.failAt != 0 && start == f.failAt {
+		return nil, nil, errors.New("throttled")
+	}
+	end := min(start+f.pageSize, len(f.items))
+	if end == len(f.items) {
+		return f.items[start:end], nil, nil
+	}
+	return f.items[start:end], aws.String(strconv.Itoa(end)), nil
+}
+
+// fakeEC2 pages the describe calls of the lookups by the input's NextToken, the other calls of ec2API panic
+type fakeEC2 struct {
+	ec2API
+	vpcs        fakePages[types.Vpc]
+	subnets     fakePages[types.Subnet]
+	routeTables fakePages[types.RouteTable]
+	natGWs      fakePages[types.NatGateway]
+	// filters are the filters of each call
+	filters [][]types.Filter
+}
+
+func (f *fakeEC2) DescribeVpcs(_ context.Context, input *ec2.DescribeVpcsInput, _ ...func(*ec2.Options)) (*ec2.DescribeVpcsOutput, error) {
+	f.filters = append(f.filters, input.Filters)
+	vpcs, nextToken, err := f.vpcs.page(input.NextToken)
+	if err != nil {
+		return nil, err
+	}
+	return &ec2.DescribeVpcsOutput{Vpcs: vpcs, NextToken: nextToken}, nil
+}
+
+func (f *fakeEC2) DescribeSubnets(_ context.Context, input *ec2.DescribeSubnetsInput, _ ...func(*ec2.Options)) (*ec2.DescribeSubnetsOutput, error) {
+	f.filters = append(f.filters, input.Filters)
+	subnets, nextToken, err := f.subnets.page(input.NextToken)
+	if err != nil {
+		return nil, err
+	}
+	return &ec2.DescribeSubnetsOutput{Subnets: subnets, NextToken: nextToken}, nil
+}
+
+func (f *fakeEC2) DescribeRouteTables(_ context.Context, input *ec2.DescribeRouteTablesInput, _ ...func(*ec2.Options)) (*ec2.DescribeRouteTablesOutput, error) {
+	f.filters = append(f.filters, input.Filters)
+	routeTables, nextToken, err := f.routeTables.page(input.NextToken)
+	if err != nil {
+		return nil, err
+	}
+	return &ec2.DescribeRouteTablesOutput{RouteTables: routeTables, NextToken: nextToken}, nil
+}
+
+func (f *fakeEC2) DescribeNatGateways(_ context.Context, input *ec2.DescribeNatGatewaysInput, _ ...func(*ec2.Options)) (*ec2.DescribeNatGatewaysOutput, error) {
+	f.filters = append(f.filters, input.Filter)
+	natGWs, nextToken, err := f.natGWs.page(input.NextToken)
+	if err != nil {
+		return nil, err
+	}
+	return &ec2.DescribeNatGatewaysOutput{NatGateways: natGWs, NextToken: nextToken}, nil
+}
+
+// filterValues returns the values of the named filter
+func filterValues(filters []types.Filter, name string) []string {
+	filter, _ := lo.Find(filters, func(filter types.Filter) bool { return *filter.Name == name })
+	return filter.Values
+}
+
+// fakeRoute53 pages ListHostedZonesByVPC by the input's NextToken
+type fakeRoute53 struct {
+	hostedZones fakePages[route53types.HostedZoneSummary]
+	inputs      []route53.ListHostedZonesByVPCInput
+}
+
+func (f *fakeRoute53) ListHostedZonesByVPC(_ context.Context, input *route53.ListHostedZonesByVPCInput, _ ...func(*route53.Options)) (*route53.ListHostedZonesByVPCOutput, error) {
+	f.inputs = append(f.inputs, *input)
+	hostedZones, nextToken, err := f.hostedZones.page(input.NextToken)
+	if err != nil {
+		return nil, err
+	}
+	return &route53.ListHostedZonesByVPCOutput{HostedZoneSummaries: hostedZones, NextToken: nextToken}, nil
+}
+
+func fakeSubnets(count int) []types.Subnet {
+	return lo.Times(count, func(i int) types.Subnet { return types.Subnet{SubnetId: aws.String(fmt.Sprintf("subnet-%d", i))} })
+}
+
+func TestAllPages(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		items     int
+		pageSize  int
+		wantCalls int
+	}{
+		{name: "empty", items: 0, pageSize: 5, wantCalls: 1},
+		{name: "single page", items: 3, pageSize: 5, wantCalls: 1},
+		{name: "exact pages", items: 10, pageSize: 5, wantCalls: 2},
+		{name: "partial last page", items: 11, pageSize: 5, wantCalls: 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := &fakeEC2{subnets: fakePages[types.Subnet]{items: fakeSubnets(tc.items), pageSize: tc.pageSize}}
+			subnets, err := allPages(context.Background(), ec2.NewDescribeSubnetsPaginator(fake, &ec2.DescribeSubnetsInput{}),
+				func(page *ec2.DescribeSubnetsOutput) []types.Subnet { return page.Subnets })
+			if err != nil {
+				t.Fatalf("allPages() error = %v", err)
+			}
+			if len(subnets) != tc.items {
+				t.Errorf("allPages() returned %d subnets, want %d", len(subnets), tc.items)
+			}
+			for i, subnet := range subnets {
+				if want := fmt.Sprintf("subnet-%d", i); *subnet.SubnetId != want {
+					t.Errorf("allPages()[%d] = %s, want %s", i, *subnet.SubnetId, want)
+				}
+			}
+			if fake.subnets.calls != tc.wantCalls {
+				t.Errorf("DescribeSubnets called %d times, want %d", fake.subnets.calls, tc.wantCalls)
+			}
+		})
+	}
+}
+
+func TestAllPagesError(t *testing.T) {
+	fake := &fakeEC2{subnets: fakePages[types.Subnet]{items: fakeSubnets(12), pageSize: 5, failAt: 10}}
+	subnets, err := allPages(context.Background(), ec2.NewDescribeSubnetsPaginator(fake, &ec2.DescribeSubnetsInput{}),
+		func(page *ec2.DescribeSubnetsOutput) []types.Subnet { return page.Subnets })
+	if err == nil {
+		t.Fatal("allPages() error = nil, want the error of the failed page")
+	}
+	if len(subnets) != 10 {
+		t.Errorf("allPages() returned %d subnets before the error, want 10", len(subnets))
+	}
+}
+
+func TestListHostedZonesByVPC(t *testing.T) {
+	hostedZones := lo.Times(7, func(i int) route53types.HostedZoneSummary {
+		return route53types.HostedZoneSummary{HostedZoneId: aws.String(fmt.Sprintf("Z%d", i)), Name: aws.String(fmt.Sprintf("zone%d.internal", i))}
+	})
+	fake := &fakeRoute53{hostedZones: fakePages[route53types.HostedZoneSummary]{items: hostedZones, pageSize: 3}}
+	got, err := listHostedZonesByVPC(context.Background(), fake, "vpc-123", "us-west-2")
+	if err != nil {
+		t.Fatalf("listHostedZonesByVPC() error = %v", err)
+	}
+	if len(got) != len(hostedZones) {
+		t.Fatalf("listHostedZonesByVPC() returned %d hosted zones, want %d", len(got), len(hostedZones))
+	}
+	for i := range got {
+		if *got[i].HostedZoneId != *hostedZones[i].HostedZoneId {
+			t.Errorf("listHostedZonesByVPC()[%d] = %s, want %s", i, *got[i].HostedZoneId, *hostedZones[i].HostedZoneId)
+		}
+	}
+	if len(fake.inputs) != 3 {
+		t.Fatalf("ListHostedZonesByVPC called %d times, want 3", len(fake.inputs))
+	}
+	for i, input := range fake.inputs {
+		if *input.VPCId != "vpc-123" || input.VPCRegion != route53types.VPCRegionUsWest2 {
+			t.Errorf("call %d selected VPC %s in %s, want vpc-123 in us-west-2", i, *input.VPCId, input.VPCRegion)
+		}
+	}
+	if fake.inputs[0].NextToken != nil || lo.FromPtr(fake.inputs[2].NextToken) != "6" {
+		t.Errorf("next tokens = %v, %v, want none for the first call and 6 for the last", fake.inputs[0].NextToken, fake.inputs[2].NextToken)
+	}
+}
+
+func TestListHostedZonesByVPCError(t *testing.T) {
+	hostedZones := lo.Times(4, func(i int) route53types.HostedZoneSummary {
+		return route53types.HostedZoneSummary{HostedZoneId: aws.String(fmt.Sprintf("Z%d", i))}
+	})
+	fake := &fakeRoute53{hostedZones: fakePages[route53types.HostedZoneSummary]{items: hostedZones, pageSize: 2, failAt: 2}}
+	if _, err := listHostedZonesByVPC(context.Background(), fake, "vpc-123", "us-west-2"); err == nil {
+		t.Fatal("listHostedZonesByVPC() error = nil, want the error of the failed page")
+	}
+}
+
+func TestList(t *testing.T) {
+	vpcs := lo.Times(8, func(i int) types.Vpc {
+		vpc := types.Vpc{VpcId: aws.String(fmt.Sprintf("vpc-%d", i))}
+		// VPCs without a name are not listed
+		if i != 5 {
+			vpc.Tags = []types.Tag{{Key: aws.String("Name"), Value: aws.String(fmt.Sprintf("%s-%d", lo.Ternary(i%2 == 0, "dev", "prod"), i))}}
+		}
+		return vpc
+	})
+	for _, tc := range []struct {
+		name string
+		glob string
+		want []string
+	}{
+		{name: "all", want: []string{"dev-0", "prod-1", "dev-2", "prod-3", "dev-4", "dev-6", "prod-7"}},
+		{name: "glob", glob: "prod-*", want: []string{"prod-1", "prod-3", "prod-7"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := &fakeEC2{vpcs: fakePages[types.Vpc]{items: vpcs, pageSize: 3}}
+			names, err := Client{ec2Client: fake}.List(context.Background(), ListOptions{Name: tc.glob, Tags: map[string]string{"team": "net"}})
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if strings.Join(names, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("List() = %v, want %v", names, tc.want)
+			}
+			if fake.vpcs.calls != 3 {
+				t.Errorf("DescribeVpcs called %d times, want 3", fake.vpcs.calls)
+			}
+			for i, filters := range fake.filters {
+				if !lo.Contains(filterValues(filters, "tag:team"), "net") || !lo.Contains(filterValues(filters, "tag:"+CreatedByTagKey), CreatedByTagValue) {
+					t.Errorf("call %d filters = %v, want the tag selector and the vpcctl tag", i, filters)
+				}
+			}
+		})
+	}
+}
+
+func TestGetLookups(t *testing.T) {
+	fake := &fakeEC2{
+		subnets: fakePages[types.Subnet]{items: fakeSubnets(7), pageSize: 3},
+		routeTables: fakePages[types.RouteTable]{items: lo.Times(4, func(i int) types.RouteTable {
+			return types.RouteTable{RouteTableId: aws.String(fmt.Sprintf("rtb-%d", i))}
+		}), pageSize: 3},
+		natGWs: fakePages[types.NatGateway]{items: lo.Times(5, func(i int) types.NatGateway {
+			return types.NatGateway{NatGatewayId: aws.String(fmt.Sprintf("nat-%d", i))}
+		}), pageSize: 2},
+	}
+	client := Client{ec2Client: fake}
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name      string
+		lookup    func() ([]string, error)
+		calls     func() int
+		idFilter  string
+		wantIDs   int
+		wantCalls int
+	}{
+		{
+			name: "getSubnets",
+			lookup: func() ([]string, error) {
+				subnets, err := client.getSubnets(ctx, "vpc-123", GetOptions{})
+				return lo.Map(subnets, func(subnet *types.Subnet, _ int) string { return *subnet.SubnetId }), err
+			},
+			calls: func() int { return fake.subnets.calls }, wantIDs: 7, wantCalls: 3,
+		},
+		{
+			name: "getRouteTables",
+			lookup: func() ([]string, error) {
+				routeTables, err := client.getRouteTables(ctx, "vpc-123", GetOptions{})
+				return lo.Map(routeTables, func(rt *types.RouteTable, _ int) string { return *rt.RouteTableId }), err
+			},
+			calls: func() int { return fake.routeTables.calls }, wantIDs: 4, wantCalls: 2,
+		},
+		{
+			name: "getNATGWs",
+			lookup: func() ([]string, error) {
+				natGWs, err := client.getNATGWs(ctx, "vpc-123", GetOptions{})
+				return lo.Map(natGWs, func(natGW *types.NatGateway, _ int) string { return *natGW.NatGatewayId }), err
+			},
+			calls: func() int { return fake.natGWs.calls }, wantIDs: 5, wantCalls: 3,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake.filters = nil
+			ids, err := tc.lookup()
+			if err != nil {
+				t.Fatalf("%s() error = %v", tc.name, err)
+			}
+			// every item is returned once, so the pointers to the items of a page are distinct
+			if len(ids) != tc.wantIDs || len(lo.Uniq(ids)) != tc.wantIDs {
+				t.Errorf("%s() = %v, want %d distinct resources", tc.name, ids, tc.wantIDs)
+			}
+			if tc.calls() != tc.wantCalls {
+				t.Errorf("%s() made %d calls, want %d", tc.name, tc.calls(), tc.wantCalls)
+			}
+			for i, filters := range fake.filters {
+				if !lo.Contains(filterValues(filters, "vpc-id"), "vpc-123") {
+					t.Errorf("call %d filters = %v, want the VPC's ID", i, filters)
+				}
+			}
+		})
+	}
+}
+
+func TestGetSubnetsError(t *testing.T) {
+	fake := &fakeEC2{subnets: fakePages[types.Subnet]{items: fakeSubnets(7), pageSize: 3, failAt: 6}}
+	if _, err := (Client{ec2Client: fake}).getSubnets(context.Background(), "vpc-123", GetOptions{}); err == nil {
+		t.Fatal("getSubnets() error = nil, want the error of the failed page")
+	}
+}
@@ -0,0 +1,283 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
+	"github.com/samber/lo"
+)
+
+// PeerOptions peers the From VPC (requester) with the To VPC (accepter).
+// The From VPC is looked up with the client's config, the To VPC in ToRegion and
+// with ToRoleARN credentials when set, which allows cross-region and cross-account peering.
+type PeerOptions struct {
+	From      string
+	To        string
+	ToRegion  string
+	ToRoleARN string
+}
+
+// PeeringDetails describes a peering connection and both VPCs that are part of it
+type PeeringDetails struct {
+	PeeringConnection *types.VpcPeeringConnection
+	Requester         *Details
+	Accepter          *Details
+}
+
+// livePeeringStates are the peering connection states that can carry or will carry traffic
+var livePeeringStates = []string{
+	string(types.VpcPeeringConnectionStateReasonCodeInitiatingRequest),
+	string(types.VpcPeeringConnectionStateReasonCodePendingAcceptance),
+	string(types.VpcPeeringConnectionStateReasonCodeProvisioning),
+	string(types.VpcPeeringConnectionStateReasonCodeActive),
+}
+
+func (v Client) Peer(ctx context.Context, opts PeerOptions) (*PeeringDetails, error) {
+	peeringDetails := &PeeringDetails{}
+	accepterClient := v.targetClient(opts.ToRegion, opts.ToRoleARN)
+	requester, err := v.Get(ctx, GetOptions{Name: opts.From})
+	peeringDetails.Requester = requester
+	if err != nil {
+		return peeringDetails, err
+	}
+	accepter, err := accepterClient.Get(ctx, GetOptions{Name: opts.To})
+	peeringDetails.Accepter = accepter
+	if err != nil {
+		return peeringDetails, err
+	}
+	if err := validatePeeringCIDRs(requester.VPC, accepter.VPC); err != nil {
+		return peeringDetails, err
+	}
+	// A peering connection of a previous run that failed before it was active or routed is resumed
+	peeringConnection, ok := findPeeringConnection(requester, *accepter.VPC.VpcId)
+	if ok {
+		v.exists(ActionCreate, "VPC Peering Connection", *peeringConnection.VpcPeeringConnectionId)
+	} else {
+		t := v.track(ActionCreate, "VPC Peering Connection", *requester.VPC.VpcId, *accepter.VPC.VpcId)
+		peeringConnection, err = v.createPeeringConnection(ctx, requester.VPC, accepter.VPC, accepterClient.cfg.Region, fmt.Sprintf("%s-%s", opts.From, opts.To))
+		if err := t.done(err); err != nil {
+			return peeringDetails, err
+		}
+	}
+	peeringDetails.PeeringConnection = peeringConnection
+	peeringID := *peeringConnection.VpcPeeringConnectionId
+
+	if peeringConnection.Status == nil || peeringConnection.Status.Code != types.VpcPeeringConnectionStateReasonCodeActive {
+		t := v.track(ActionAccept, "VPC Peering Connection", peeringID)
+		peeringConnection, err = v.acceptPeeringConnection(ctx, accepterClient, peeringConnection)
+		if err := t.done(err, peeringID); err != nil {
+			return peeringDetails, err
+		}
+		peeringDetails.PeeringConnection = peeringConnection
+	}
+
+	routeTableIDs := lo.Map(append(requester.RouteTables, accepter.RouteTables...), func(rt *types.RouteTable, _ int) string { return *rt.RouteTableId })
+	t := v.track(ActionCreate, fmt.Sprintf("routes to %s in Route Tables", peeringID), routeTableIDs...)
+	if err := v.createPeeringRoutes(ctx, peeringID, requester.RouteTables, vpcCIDRs(accepter.VPC)); err != nil {
+		return peeringDetails, t.done(err)
+	}
+	if err := accepterClient.createPeeringRoutes(ctx, peeringID, accepter.RouteTables, vpcCIDRs(requester.VPC)); err != nil {
+		return peeringDetails, t.done(err)
+	}
+	return peeringDetails, t.done(nil, routeTableIDs...)
+}
+
+func (v Client) Unpeer(ctx context.Context, opts PeerOptions) (*PeeringDetails, error) {
+	peeringDetails := &PeeringDetails{}
+	accepterClient := v.targetClient(opts.ToRegion, opts.ToRoleARN)
+	requester, err := v.Get(ctx, GetOptions{Name: opts.From})
+	peeringDetails.Requester = requester
+	if err != nil {
+		return peeringDetails, err
+	}
+	accepter, err := accepterClient.Get(ctx, GetOptions{Name: opts.To})
+	peeringDetails.Accepter = accepter
+	if err != nil {
+		return peeringDetails, err
+	}
+	peeringConnection, ok := findPeeringConnection(requester, *accepter.VPC.VpcId)
+	if !ok {
+		return peeringDetails, fmt.Errorf("VPC %s is not peered with VPC %s", opts.From, opts.To)
+	}
+	peeringDetails.PeeringConnection = peeringConnection
+	peeringID := *peeringConnection.VpcPeeringConnectionId
+
+	t := v.track(ActionDelete, fmt.Sprintf("routes to %s", peeringID))
+	if err := v.deletePeeringRoutes(ctx, peeringID, requester.RouteTables); err != nil {
+		return peeringDetails, t.done(err)
+	}
+	if err := t.done(accepterClient.deletePeeringRoutes(ctx, peeringID, accepter.RouteTables)); err != nil {
+		return peeringDetails, err
+	}
+	t = v.track(ActionDelete, "VPC Peering Connection", peeringID)
+	if _, err := v.ec2Client.DeleteVpcPeeringConnection(ctx, &ec2.DeleteVpcPeeringConnectionInput{VpcPeeringConnectionId: &peeringID}); err != nil {
+		return peeringDetails, t.done(err)
+	}
+	waiter := ec2.NewVpcPeeringConnectionDeletedWaiter(v.ec2Client)
+	err = waiter.Wait(ctx, &ec2.DescribeVpcPeeringConnectionsInput{VpcPeeringConnectionIds: []string{peeringID}}, DefaultStepTimeout)
+	return peeringDetails, t.done(err, peeringID)
+}
+
+func (v Client) createPeeringConnection(ctx context.Context, requester *types.Vpc, accepter *types.Vpc, accepterRegion string, name string) (*types.VpcPeeringConnection, error) {
+	peeringOut, err := v.ec2Client.CreateVpcPeeringConnection(ctx, &ec2.CreateVpcPeeringConnectionInput{
+		VpcId:       requester.VpcId,
+		PeerVpcId:   accepter.VpcId,
+		PeerOwnerId: accepter.OwnerId,
+		PeerRegion:  aws.String(accepterRegion),
+		TagSpecifications: []types.TagSpecification{
+			{
+				ResourceType: types.ResourceTypeVpcPeeringConnection,
+				Tags: lo.Flatten([][]types.Tag{
+					defaultTags,
+					{
+						{Key: aws.String("Name"), Value: &name},
+					},
+				}),
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return peeringOut.VpcPeeringConnection, nil
+}
+
+// acceptPeeringConnection accepts the peering connection with the accepter's client unless it was already accepted,
+// and waits for it to become active
+func (v Client) acceptPeeringConnection(ctx context.Context, accepterClient Client, peeringConnection *types.VpcPeeringConnection) (*types.VpcPeeringConnection, error) {
+	peeringID := *peeringConnection.VpcPeeringConnectionId
+	if peeringConnection.Status == nil || peeringConnection.Status.Code != types.VpcPeeringConnectionStateReasonCodeProvisioning {
+		// The peering connection may take some time to show up in the accepter's region or account
+		if _, err := accepterClient.waitForPeeringState(ctx, peeringID, types.VpcPeeringConnectionStateReasonCodePendingAcceptance); err != nil {
+			return peeringConnection, err
+		}
+		if _, err := accepterClient.ec2Client.AcceptVpcPeeringConnection(ctx, &ec2.AcceptVpcPeeringConnectionInput{VpcPeeringConnectionId: &peeringID}); err != nil {
+			return peeringConnection, err
+		}
+	}
+	active, err := v.waitForPeeringState(ctx, peeringID, types.VpcPeeringConnectionStateReasonCodeActive)
+	return lo.CoalesceOrEmpty(active, peeringConnection), err
+}
+
+func (v Client) waitForPeeringState(ctx context.Context, peeringID string, state types.VpcPeeringConnectionStateReasonCode) (*types.VpcPeeringConnection, error) {
+	var peeringConnection *types.VpcPeeringConnection
+	waiter := ec2.NewVpcPeeringConnectionExistsWaiter(v.ec2Client, func(o *ec2.VpcPeeringConnectionExistsWaiterOptions) {
+		o.Retryable = func(_ context.Context, _ *ec2.DescribeVpcPeeringConnectionsInput, out *ec2.DescribeVpcPeeringConnectionsOutput, err error) (bool, error) {
+			var apiErr smithy.APIError
+			if errors.As(err, &apiErr) && apiErr.ErrorCode() == "InvalidVpcPeeringConnectionID.NotFound" {
+				return true, nil
+			}
+			if err != nil {
+				return false, err
+			}
+			if len(out.VpcPeeringConnections) == 0 {
+				return true, nil
+			}
+			peeringConnection = &out.VpcPeeringConnections[0]
+			switch peeringConnection.Status.Code {
+			case state:
+				return false, nil
+			case types.VpcPeeringConnectionStateReasonCodeFailed, types.VpcPeeringConnectionStateReasonCodeRejected:
+				return false, fmt.Errorf("VPC Peering Connection %s is %s: %s", peeringID, peeringConnection.Status.Code, lo.FromPtr(peeringConnection.Status.Message))
+			}
+			return true, nil
+		}
+	})
+	if err := waiter.Wait(ctx, &ec2.DescribeVpcPeeringConnectionsInput{VpcPeeringConnectionIds: []string{peeringID}}, DefaultStepTimeout); err != nil {
+		return peeringConnection, err
+	}
+	return peeringConnection, nil
+}
+
+func (v Client) createPeeringRoutes(ctx context.Context, peeringID string, routeTables []*types.RouteTable, destinationCIDRs []string) error {
+	for _, rt := range routeTables {
+		for _, cidr := range destinationCIDRs {
+			// routes of a previous run are kept when the peering is resumed
+			if lo.ContainsBy(rt.Routes, func(route types.Route) bool {
+				return lo.FromPtr(route.DestinationCidrBlock) == cidr && lo.FromPtr(route.VpcPeeringConnectionId) == peeringID
+			}) {
+				continue
+			}
+			if _, err := v.ec2Client.CreateRoute(ctx, &ec2.CreateRouteInput{
+				RouteTableId:           rt.RouteTableId,
+				DestinationCidrBlock:   aws.String(cidr),
+				VpcPeeringConnectionId: &peeringID,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v Client) deletePeeringRoutes(ctx context.Context, peeringID string, routeTables []*types.RouteTable) error {
+	for _, rt := range routeTables {
+		for _, route := range rt.Routes {
+			if route.VpcPeeringConnectionId == nil || *route.VpcPeeringConnectionId != peeringID {
+				continue
+			}
+			if _, err := v.ec2Client.DeleteRoute(ctx, &ec2.DeleteRouteInput{RouteTableId: rt.RouteTableId, DestinationCidrBlock: route.DestinationCidrBlock}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// findPeeringConnection finds the live peering connection between the VPC in vpcDetails and peerVPCID
+func findPeeringConnection(vpcDetails *Details, peerVPCID string) (*types.VpcPeeringConnection, bool) {
+	return lo.Find(vpcDetails.PeeringConnections, func(pcx *types.VpcPeeringConnection) bool {
+		return *pcx.RequesterVpcInfo.VpcId == peerVPCID || *pcx.AccepterVpcInfo.VpcId == peerVPCID
+	})
+}
+
+// validatePeeringCIDRs returns an error if any of the VPCs CIDR blocks overlap since overlapping VPCs cannot be peered
+func validatePeeringCIDRs(requester *types.Vpc, accepter *types.Vpc) error {
+	for _, requesterCIDR := range vpcCIDRs(requester) {
+		for _, accepterCIDR := range vpcCIDRs(accepter) {
+			requesterPrefix, err := netip.ParsePrefix(requesterCIDR)
+			if err != nil {
+				return err
+			}
+			accepterPrefix, err := netip.ParsePrefix(accepterCIDR)
+			if err != nil {
+				return err
+			}
+			if requesterPrefix.Overlaps(accepterPrefix) {
+				return fmt.Errorf("CIDR %s of %s overlaps with CIDR %s of %s", requesterCIDR, *requester.VpcId, accepterCIDR, *accepter.VpcId)
+			}
+		}
+	}
+	return nil
+}
+
+// vpcCIDRs returns all associated IPv4 CIDR blocks of the VPC
+func vpcCIDRs(vpc *types.Vpc) []string {
+	cidrs := lo.FilterMap(vpc.CidrBlockAssociationSet, func(assoc types.VpcCidrBlockAssociation, _ int) (string, bool) {
+		return lo.FromPtr(assoc.CidrBlock), assoc.CidrBlockState != nil && assoc.CidrBlockState.State == types.VpcCidrBlockStateCodeAssociated
+	})
+	if len(cidrs) == 0 && vpc.CidrBlock != nil {
+		return []string{*vpc.CidrBlock}
+	}
+	return cidrs
+}
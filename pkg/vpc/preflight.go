@@ -0,0 +1,216 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vpc
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
+	"github.com/samber/lo"
+)
+
+const (
+	QuotaSourceServiceQuotas = "service quotas"
+	// QuotaSourceDefault is used when the applied quota cannot be read from Service Quotas
+	QuotaSourceDefault = "default"
+)
+
+// quota is a Service Quotas quota and its default value
+type quota struct {
+	name         string
+	serviceCode  string
+	quotaCode    string
+	defaultValue int
+}
+
+var (
+	vpcQuota        = quota{name: "VPCs per Region", serviceCode: "vpc", quotaCode: "L-F678F1CE", defaultValue: 5}
+	igwQuota        = quota{name: "Internet gateways per Region", serviceCode: "vpc", quotaCode: "L-A4707A72", defaultValue: 5}
+	natGWQuota      = quota{name: "NAT gateways per Availability Zone", serviceCode: "vpc", quotaCode: "L-FE5A380F", defaultValue: 5}
+	elasticIPQuota  = quota{name: "EC2-VPC Elastic IPs", serviceCode: "ec2", quotaCode: "L-0263D0A3", defaultValue: 5}
+	liveNATGWStates = []string{string(types.NatGatewayStatePending), string(types.NatGatewayStateAvailable)}
+)
+
+// QuotaCheck is the usage of a quota and the number of resources a create needs
+type QuotaCheck struct {
+	Quota     string
+	QuotaCode string
+	Limit     int
+	// Source is QuotaSourceServiceQuotas or QuotaSourceDefault
+	Source string
+	Used   int
+	Needed int
+}
+
+// Exceeded returns true when the needed resources do not fit in the quota
+func (c QuotaCheck) Exceeded() bool {
+	return c.Used+c.Needed > c.Limit
+}
+
+// CIDROverlap is an existing VPC whose CIDR overlaps with the CIDR of the VPC to create
+type CIDROverlap struct {
+	VPCID string
+	Name  string
+	CIDR  string
+}
+
+// PreflightReport is the result of the checks that run before any resource is created. It is returned as the error of
+// Create when a check failed.
+type PreflightReport struct {
+	CIDR     string
+	Quotas   []QuotaCheck
+	Overlaps []CIDROverlap
+}
+
+// Failed returns true when a quota would be exceeded or the CIDR overlaps with an existing VPC
+func (r PreflightReport) Failed() bool {
+	return len(r.Overlaps) != 0 || lo.ContainsBy(r.Quotas, func(check QuotaCheck) bool { return check.Exceeded() })
+}
+
+func (r PreflightReport) Error() string {
+	var lines []string
+	for _, check := range lo.Filter(r.Quotas, func(check QuotaCheck, _ int) bool { return check.Exceeded() }) {
+		lines = append(lines, fmt.Sprintf("  %s (%s): %d used + %d needed > %d (%s quota)", check.Quota, check.QuotaCode, check.Used, check.Needed, check.Limit, check.Source))
+	}
+	for _, overlap := range r.Overlaps {
+		vpc := lo.Ternary(overlap.Name == "", overlap.VPCID, fmt.Sprintf("%s (%s)", overlap.VPCID, overlap.Name))
+		lines = append(lines, fmt.Sprintf("  CIDR %s overlaps with %s of VPC %s", r.CIDR, overlap.CIDR, vpc))
+	}
+	return fmt.Sprintf("pre-flight checks failed, no resources were created:\n%s\nRequest quota increases, choose another CIDR or skip the checks", strings.Join(lines, "\n"))
+}
+
+// Preflight checks that the quotas have room for the resources of the VPC that are missing from vpcDetails and that
+// the CIDR of a new VPC does not overlap with the existing VPCs of the region
+func (v Client) Preflight(ctx context.Context, vpcDetails *Details, opts CreateOptions) (*PreflightReport, error) {
+	report := &PreflightReport{CIDR: opts.CIDR}
+	if vpcDetails.VPC == nil {
+		vpcs, err := allPages(ctx, ec2.NewDescribeVpcsPaginator(v.ec2Client, &ec2.DescribeVpcsInput{}), func(page *ec2.DescribeVpcsOutput) []types.Vpc { return page.Vpcs })
+		if err != nil {
+			return nil, err
+		}
+		report.Quotas = append(report.Quotas, v.quotaCheck(ctx, vpcQuota, len(vpcs), 1))
+		if opts.IPAMPoolID == "" {
+			if report.Overlaps, err = cidrOverlaps(opts.CIDR, vpcs); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if vpcDetails.InternetGateway == nil {
+		igws, err := allPages(ctx, ec2.NewDescribeInternetGatewaysPaginator(v.ec2Client, &ec2.DescribeInternetGatewaysInput{}),
+			func(page *ec2.DescribeInternetGatewaysOutput) []types.InternetGateway { return page.InternetGateways })
+		if err != nil {
+			return nil, err
+		}
+		report.Quotas = append(report.Quotas, v.quotaCheck(ctx, igwQuota, len(igws), 1))
+	}
+	if len(vpcDetails.NATGateways) == 0 && PlannedBillableResources(opts).NATGateways != 0 {
+		natGWChecks, err := v.natGWQuotaChecks(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		report.Quotas = append(report.Quotas, natGWChecks...)
+	}
+	return report, nil
+}
+
+// natGWQuotaChecks checks the quotas of the NAT gateway, which is created in the AZ of the first public subnet, and its EIP
+func (v Client) natGWQuotaChecks(ctx context.Context, opts CreateOptions) ([]QuotaCheck, error) {
+	subnets := opts.Subnets
+	if len(subnets) == 0 {
+		// only the AZs of the default subnets are used, which don't depend on the CIDR that IPAM allocates
+		var err error
+		if subnets, err = DefaultSubnets(v.cfg.Region, lo.CoalesceOrEmpty(opts.CIDR, DefaultCIDR)); err != nil {
+			return nil, err
+		}
+	}
+	az := fmt.Sprintf("%sa", v.cfg.Region)
+	if subnet, ok := lo.Find(subnets, func(subnet CreateSubnetOptions) bool { return subnet.Public }); ok {
+		az = subnet.AZ
+	}
+	natGWs, err := allPages(ctx, ec2.NewDescribeNatGatewaysPaginator(v.ec2Client, &ec2.DescribeNatGatewaysInput{
+		Filter: []types.Filter{{Name: aws.String("state"), Values: liveNATGWStates}},
+	}), func(page *ec2.DescribeNatGatewaysOutput) []types.NatGateway { return page.NatGateways })
+	if err != nil {
+		return nil, err
+	}
+	azNATGWs := 0
+	if len(natGWs) != 0 {
+		subnets, err := allPages(ctx, ec2.NewDescribeSubnetsPaginator(v.ec2Client, &ec2.DescribeSubnetsInput{
+			SubnetIds: lo.Uniq(lo.Map(natGWs, func(natGW types.NatGateway, _ int) string { return *natGW.SubnetId })),
+		}), func(page *ec2.DescribeSubnetsOutput) []types.Subnet { return page.Subnets })
+		if err != nil {
+			return nil, err
+		}
+		azSubnets := lo.SliceToMap(subnets, func(subnet types.Subnet) (string, string) { return *subnet.SubnetId, *subnet.AvailabilityZone })
+		azNATGWs = lo.CountBy(natGWs, func(natGW types.NatGateway) bool { return azSubnets[*natGW.SubnetId] == az })
+	}
+	addresses, err := v.ec2Client.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{
+		Filters: []types.Filter{{Name: aws.String("domain"), Values: []string{string(types.DomainTypeVpc)}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	natGWCheck := v.quotaCheck(ctx, natGWQuota, azNATGWs, 1)
+	natGWCheck.Quota = fmt.Sprintf("%s (%s)", natGWCheck.Quota, az)
+	return []QuotaCheck{natGWCheck, v.quotaCheck(ctx, elasticIPQuota, len(addresses.Addresses), 1)}, nil
+}
+
+// preflight runs the pre-flight checks as a step of Create, the report is returned as the error when a check failed
+func (v Client) preflight(ctx context.Context, vpcDetails *Details, opts CreateOptions) error {
+	t := v.track(ActionCheck, "Quotas and CIDR")
+	report, err := v.Preflight(ctx, vpcDetails, opts)
+	if err == nil && report.Failed() {
+		err = report
+	}
+	return t.done(err)
+}
+
+// quotaCheck reads the applied value of a quota from Service Quotas, the default value is used when it cannot be read,
+// e.g. when the credentials are not allowed to read quotas
+func (v Client) quotaCheck(ctx context.Context, q quota, used int, needed int) QuotaCheck {
+	check := QuotaCheck{Quota: q.name, QuotaCode: q.quotaCode, Limit: q.defaultValue, Source: QuotaSourceDefault, Used: used, Needed: needed}
+	quotaOut, err := v.quotasClient.GetServiceQuota(ctx, &servicequotas.GetServiceQuotaInput{ServiceCode: &q.serviceCode, QuotaCode: &q.quotaCode})
+	if err == nil && quotaOut.Quota != nil && quotaOut.Quota.Value != nil {
+		check.Limit, check.Source = int(*quotaOut.Quota.Value), QuotaSourceServiceQuotas
+	}
+	return check
+}
+
+// cidrOverlaps returns the associated CIDRs of the VPCs that overlap with cidr
+func cidrOverlaps(cidr string, vpcs []types.Vpc) ([]CIDROverlap, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VPC CIDR %s: %w", cidr, err)
+	}
+	var overlaps []CIDROverlap
+	for _, vpc := range vpcs {
+		for _, association := range vpc.CidrBlockAssociationSet {
+			if association.CidrBlockState == nil || association.CidrBlockState.State != types.VpcCidrBlockStateCodeAssociated {
+				continue
+			}
+			vpcPrefix, err := netip.ParsePrefix(*association.CidrBlock)
+			if err == nil && vpcPrefix.Overlaps(prefix) {
+				overlaps = append(overlaps, CIDROverlap{VPCID: *vpc.VpcId, Name: lo.FromPtr(nameTag(vpc.Tags)), CIDR: *association.CidrBlock})
+			}
+		}
+	}
+	return overlaps, nil
+}
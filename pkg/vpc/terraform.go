@@ -0,0 +1,288 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/samber/lo"
+)
+
+// terraformVars is the terraform.tfvars.json document for a VPC
+type terraformVars struct {
+	VPCID                string              `json:"vpc_id"`
+	CIDR                 string              `json:"cidr"`
+	PublicSubnetIDs      []string            `json:"public_subnet_ids"`
+	PrivateSubnetIDs     []string            `json:"private_subnet_ids"`
+	PublicSubnetsByAZ    map[string][]string `json:"public_subnets_by_az"`
+	PrivateSubnetsByAZ   map[string][]string `json:"private_subnets_by_az"`
+	PublicRouteTableIDs  []string            `json:"public_route_table_ids"`
+	PrivateRouteTableIDs []string            `json:"private_route_table_ids"`
+	InternetGatewayID    string              `json:"internet_gateway_id,omitempty"`
+	NATGatewayIDs        []string            `json:"nat_gateway_ids,omitempty"`
+	// NATGatewayID is the first of NATGatewayIDs, it is kept for configs that declare the nat_gateway_id variable
+	NATGatewayID string `json:"nat_gateway_id,omitempty"`
+}
+
+// OutputTerraform outputs a terraform.tfvars.json document with the IDs of the VPC resources
+func (d Details) OutputTerraform() (string, error) {
+	privateSubnets := lo.Filter(d.Subnets, func(subnet *types.Subnet, _ int) bool { return SubnetType(subnet) == SubnetTypePrivate })
+	publicSubnets := lo.Filter(d.Subnets, func(subnet *types.Subnet, _ int) bool { return SubnetType(subnet) == SubnetTypePublic })
+	publicRouteTables := lo.Filter(d.RouteTables, func(rt *types.RouteTable, _ int) bool { return isPublicRouteTable(rt) })
+	privateRouteTables := lo.Filter(d.RouteTables, func(rt *types.RouteTable, _ int) bool { return !isPublicRouteTable(rt) })
+	subnetIDs := func(subnets []*types.Subnet) []string {
+		return lo.Map(subnets, func(subnet *types.Subnet, _ int) string { return *subnet.SubnetId })
+	}
+	// the config and adopted VPCs can have several subnets of a type in an AZ
+	subnetsByAZ := func(subnets []*types.Subnet) map[string][]string {
+		return lo.MapValues(lo.GroupBy(subnets, func(subnet *types.Subnet) string { return *subnet.AvailabilityZone }), func(subnets []*types.Subnet, _ string) []string {
+			return subnetIDs(subnets)
+		})
+	}
+	routeTableIDs := func(routeTables []*types.RouteTable) []string {
+		return lo.Map(routeTables, func(rt *types.RouteTable, _ int) string { return *rt.RouteTableId })
+	}
+	tfVars := terraformVars{
+		VPCID:                *d.VPC.VpcId,
+		CIDR:                 *d.VPC.CidrBlock,
+		PublicSubnetIDs:      subnetIDs(publicSubnets),
+		PrivateSubnetIDs:     subnetIDs(privateSubnets),
+		PublicSubnetsByAZ:    subnetsByAZ(publicSubnets),
+		PrivateSubnetsByAZ:   subnetsByAZ(privateSubnets),
+		PublicRouteTableIDs:  routeTableIDs(publicRouteTables),
+		PrivateRouteTableIDs: routeTableIDs(privateRouteTables),
+	}
+	if d.InternetGateway != nil {
+		tfVars.InternetGatewayID = *d.InternetGateway.InternetGatewayId
+	}
+	tfVars.NATGatewayIDs = lo.Map(d.NATGateways, func(natGW *types.NatGateway, _ int) string { return *natGW.NatGatewayId })
+	tfVars.NATGatewayID = lo.FirstOrEmpty(tfVars.NATGatewayIDs)
+	tfVarsJSON, err := json.MarshalIndent(tfVars, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(tfVarsJSON), nil
+}
+
+// terraformResource is a resource to import into terraform
+type terraformResource struct {
+	Type       string
+	Name       string
+	ImportID   string
+	Attributes []terraformAttribute
+	Tags       map[string]string
+}
+
+type terraformAttribute struct {
+	Key   string
+	Value string
+}
+
+// Address is the terraform address of the resource
+func (r terraformResource) Address() string {
+	return fmt.Sprintf("%s.%s", r.Type, r.Name)
+}
+
+var terraformImportTemplate = template.Must(template.New("terraform-import").Parse(`{{- range . }}
+import {
+  to = {{ .Address }}
+  id = "{{ .ImportID }}"
+}
+
+resource "{{ .Type }}" "{{ .Name }}" {
+{{- range .Attributes }}
+  {{ .Key }} = {{ .Value }}
+{{- end }}
+{{- if .Tags }}
+
+  tags = {
+{{- range $key, $value := .Tags }}
+    {{ printf "%q" $key }} = {{ printf "%q" $value }}
+{{- end }}
+  }
+{{- end }}
+}
+{{ end -}}
+`))
+
+// OutputTerraformImport outputs terraform import blocks and matching resource skeletons for the VPC resources,
+// so that a VPC created with vpcctl can be managed by terraform
+func (d Details) OutputTerraformImport() (string, error) {
+	var resources []terraformResource
+	vpcResource := terraformResource{
+		Type:     "aws_vpc",
+		Name:     "this",
+		ImportID: *d.VPC.VpcId,
+		Attributes: []terraformAttribute{
+			{Key: "cidr_block", Value: fmt.Sprintf("%q", *d.VPC.CidrBlock)},
+			{Key: "enable_dns_support", Value: fmt.Sprint(d.EnableDNSSupport)},
+			{Key: "enable_dns_hostnames", Value: fmt.Sprint(d.EnableDNSHostnames)},
+		},
+		Tags: terraformTags(d.VPC.Tags),
+	}
+	resources = append(resources, vpcResource)
+	vpcRef := fmt.Sprintf("%s.id", vpcResource.Address())
+
+	subnets := append([]*types.Subnet{}, d.Subnets...)
+	sort.Slice(subnets, func(i, j int) bool {
+		return SubnetType(subnets[i])+*subnets[i].AvailabilityZone+*subnets[i].CidrBlock < SubnetType(subnets[j])+*subnets[j].AvailabilityZone+*subnets[j].CidrBlock
+	})
+	names := terraformNames{}
+	subnetRefs := map[string]string{}
+	for _, subnet := range subnets {
+		subnetResource := terraformResource{
+			Type:     "aws_subnet",
+			Name:     names.unique("aws_subnet", fmt.Sprintf("%s_%s", SubnetType(subnet), *subnet.AvailabilityZone)),
+			ImportID: *subnet.SubnetId,
+			Attributes: []terraformAttribute{
+				{Key: "vpc_id", Value: vpcRef},
+				{Key: "availability_zone", Value: fmt.Sprintf("%q", *subnet.AvailabilityZone)},
+				{Key: "cidr_block", Value: fmt.Sprintf("%q", *subnet.CidrBlock)},
+				{Key: "map_public_ip_on_launch", Value: fmt.Sprint(lo.FromPtr(subnet.MapPublicIpOnLaunch))},
+			},
+			Tags: terraformTags(subnet.Tags),
+		}
+		subnetRefs[*subnet.SubnetId] = fmt.Sprintf("%s.id", subnetResource.Address())
+		resources = append(resources, subnetResource)
+	}
+
+	var igwRef string
+	natGWRefs := map[string]string{}
+	if d.InternetGateway != nil {
+		igwResource := terraformResource{
+			Type:       "aws_internet_gateway",
+			Name:       "this",
+			ImportID:   *d.InternetGateway.InternetGatewayId,
+			Attributes: []terraformAttribute{{Key: "vpc_id", Value: vpcRef}},
+			Tags:       terraformTags(d.InternetGateway.Tags),
+		}
+		igwRef = fmt.Sprintf("%s.id", igwResource.Address())
+		resources = append(resources, igwResource)
+	}
+	for _, natGW := range d.NATGateways {
+		natGWResource := terraformResource{
+			Type:     "aws_nat_gateway",
+			Name:     names.unique("aws_nat_gateway", "this"),
+			ImportID: *natGW.NatGatewayId,
+			Attributes: []terraformAttribute{
+				{Key: "subnet_id", Value: lo.ValueOr(subnetRefs, lo.FromPtr(natGW.SubnetId), fmt.Sprintf("%q", lo.FromPtr(natGW.SubnetId)))},
+			},
+			Tags: terraformTags(natGW.Tags),
+		}
+		for i, address := range natGW.NatGatewayAddresses {
+			eipResource := terraformResource{
+				Type:       "aws_eip",
+				Name:       names.unique("aws_eip", "nat"),
+				ImportID:   *address.AllocationId,
+				Attributes: []terraformAttribute{{Key: "domain", Value: `"vpc"`}},
+			}
+			if i == 0 {
+				natGWResource.Attributes = append(natGWResource.Attributes, terraformAttribute{Key: "allocation_id", Value: fmt.Sprintf("%s.id", eipResource.Address())})
+			}
+			resources = append(resources, eipResource)
+		}
+		natGWRefs[*natGW.NatGatewayId] = fmt.Sprintf("%s.id", natGWResource.Address())
+		resources = append(resources, natGWResource)
+	}
+
+	for _, rt := range d.RouteTables {
+		rtResource := terraformResource{
+			Type:       "aws_route_table",
+			Name:       names.unique("aws_route_table", lo.Ternary(isPublicRouteTable(rt), SubnetTypePublic, SubnetTypePrivate)),
+			ImportID:   *rt.RouteTableId,
+			Attributes: []terraformAttribute{{Key: "vpc_id", Value: vpcRef}},
+			Tags:       terraformTags(rt.Tags),
+		}
+		rtRef := fmt.Sprintf("%s.id", rtResource.Address())
+		resources = append(resources, rtResource)
+		for _, route := range rt.Routes {
+			routeAttributes := []terraformAttribute{
+				{Key: "route_table_id", Value: rtRef},
+				{Key: "destination_cidr_block", Value: fmt.Sprintf("%q", lo.FromPtr(route.DestinationCidrBlock))},
+			}
+			switch {
+			case route.GatewayId != nil && strings.HasPrefix(*route.GatewayId, "igw-") && igwRef != "":
+				routeAttributes = append(routeAttributes, terraformAttribute{Key: "gateway_id", Value: igwRef})
+			case route.NatGatewayId != nil && natGWRefs[*route.NatGatewayId] != "":
+				routeAttributes = append(routeAttributes, terraformAttribute{Key: "nat_gateway_id", Value: natGWRefs[*route.NatGatewayId]})
+			default:
+				continue
+			}
+			resources = append(resources, terraformResource{
+				Type:       "aws_route",
+				Name:       names.unique("aws_route", fmt.Sprintf("%s_%s", rtResource.Name, lo.FromPtr(route.DestinationCidrBlock))),
+				ImportID:   fmt.Sprintf("%s_%s", *rt.RouteTableId, lo.FromPtr(route.DestinationCidrBlock)),
+				Attributes: routeAttributes,
+			})
+		}
+		for _, association := range rt.Associations {
+			if association.SubnetId == nil {
+				continue
+			}
+			// associations of subnets that are not part of the VPC details are named after the subnet ID
+			subnetName := lo.CoalesceOrEmpty(strings.TrimSuffix(strings.TrimPrefix(subnetRefs[*association.SubnetId], "aws_subnet."), ".id"), *association.SubnetId)
+			resources = append(resources, terraformResource{
+				Type:     "aws_route_table_association",
+				Name:     names.unique("aws_route_table_association", subnetName),
+				ImportID: fmt.Sprintf("%s/%s", *association.SubnetId, *rt.RouteTableId),
+				Attributes: []terraformAttribute{
+					{Key: "subnet_id", Value: lo.ValueOr(subnetRefs, *association.SubnetId, fmt.Sprintf("%q", *association.SubnetId))},
+					{Key: "route_table_id", Value: rtRef},
+				},
+			})
+		}
+	}
+
+	var b bytes.Buffer
+	if err := terraformImportTemplate.Execute(&b, resources); err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(b.String(), "\n"), nil
+}
+
+// isPublicRouteTable returns true if the route table routes to an internet gateway
+func isPublicRouteTable(rt *types.RouteTable) bool {
+	return lo.ContainsBy(rt.Routes, func(route types.Route) bool {
+		return route.GatewayId != nil && strings.HasPrefix(*route.GatewayId, "igw-")
+	})
+}
+
+// terraformTags converts EC2 tags to a terraform tags map, excluding AWS reserved tags
+func terraformTags(tags []types.Tag) map[string]string {
+	return lo.SliceToMap(lo.Reject(tags, func(tag types.Tag, _ int) bool { return strings.HasPrefix(*tag.Key, "aws:") }),
+		func(tag types.Tag) (string, string) { return *tag.Key, *tag.Value })
+}
+
+var invalidTerraformNameChars = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// terraformNames generates terraform resource names that are unique per resource type
+type terraformNames map[string]int
+
+func (n terraformNames) unique(resourceType string, name string) string {
+	name = strings.Trim(invalidTerraformNameChars.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	address := fmt.Sprintf("%s.%s", resourceType, name)
+	n[address]++
+	if n[address] > 1 {
+		return fmt.Sprintf("%s_%d", name, n[address])
+	}
+	return name
+}
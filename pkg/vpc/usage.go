@@ -0,0 +1,157 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vpc
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/netip"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/samber/lo"
+)
+
+const (
+	// DefaultUsageThreshold is the percent of a subnet's IPs in use above which a warning is reported
+	DefaultUsageThreshold = 80.0
+
+	// reservedSubnetIPs are the IPs AWS reserves in every subnet
+	reservedSubnetIPs = 5
+	// prefixDelegationIPs are the IPs of a /28 IPv4 prefix delegated to an ENI, e.g. by the VPC CNI in prefix mode
+	prefixDelegationIPs = 16
+)
+
+// UsageReport is the IP utilization of the subnets of a VPC
+type UsageReport struct {
+	VPCID     string
+	Name      string
+	Threshold float64
+	Subnets   []SubnetUsage
+	// Warnings are the subnets whose utilization is above the threshold
+	Warnings []string
+}
+
+// SubnetUsage is the IP utilization of a subnet and the ENIs that use its IPs
+type SubnetUsage struct {
+	SubnetID     string
+	Name         string
+	AZ           string
+	Type         string
+	CIDR         string
+	TotalIPs     int
+	AvailableIPs int
+	UsedIPs      int
+	// Utilization is the percent of the usable IPs in use, rounded to one decimal
+	Utilization float64
+	ENIs        []ENIUsage
+}
+
+// ENIUsage is the number of ENIs of an interface type and requester in a subnet and the IPs they use
+type ENIUsage struct {
+	InterfaceType string
+	// Requester is the service or principal that manages the ENIs, e.g. amazon-elb, and empty for ENIs created in the account
+	Requester string `json:",omitempty"`
+	ENIs      int
+	IPs       int
+}
+
+// UsageOptions selects the VPC of a usage report
+type UsageOptions struct {
+	GetOptions
+	// Threshold is the utilization percent above which subnets are reported as warnings, defaults to DefaultUsageThreshold
+	Threshold float64
+}
+
+// Usage reports the IP utilization of all subnets of a VPC, including subnets that vpcctl did not create
+func (v Client) Usage(ctx context.Context, opts UsageOptions) (*UsageReport, error) {
+	vpc, err := v.getVPC(ctx, opts.GetOptions)
+	if err != nil {
+		return nil, err
+	}
+	vpcFilter := []types.Filter{{Name: aws.String("vpc-id"), Values: []string{*vpc.VpcId}}}
+	subnets, err := allPages(ctx, ec2.NewDescribeSubnetsPaginator(v.ec2Client, &ec2.DescribeSubnetsInput{Filters: vpcFilter}),
+		func(page *ec2.DescribeSubnetsOutput) []types.Subnet { return page.Subnets })
+	if err != nil {
+		return nil, err
+	}
+	enis, err := allPages(ctx, ec2.NewDescribeNetworkInterfacesPaginator(v.ec2Client, &ec2.DescribeNetworkInterfacesInput{Filters: vpcFilter}),
+		func(page *ec2.DescribeNetworkInterfacesOutput) []types.NetworkInterface {
+			return page.NetworkInterfaces
+		})
+	if err != nil {
+		return nil, err
+	}
+	report := &UsageReport{VPCID: *vpc.VpcId, Name: lo.FromPtr(nameTag(vpc.Tags)), Threshold: lo.Ternary(opts.Threshold == 0, DefaultUsageThreshold, opts.Threshold)}
+	subnetENIs := lo.GroupBy(enis, func(eni types.NetworkInterface) string { return lo.FromPtr(eni.SubnetId) })
+	// IPv6 only subnets do not have an IPv4 CIDR
+	for _, subnet := range lo.Filter(subnets, func(subnet types.Subnet, _ int) bool { return subnet.CidrBlock != nil }) {
+		usage, err := subnetUsage(&subnet, subnetENIs[*subnet.SubnetId])
+		if err != nil {
+			return nil, err
+		}
+		if usage.Utilization > report.Threshold {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("%s %s (%s) is %.1f%% utilized, %d IPs available", usage.Type, usage.SubnetID, usage.AZ, usage.Utilization, usage.AvailableIPs))
+		}
+		report.Subnets = append(report.Subnets, usage)
+	}
+	sort.SliceStable(report.Subnets, func(i, j int) bool {
+		return report.Subnets[i].AZ < report.Subnets[j].AZ || (report.Subnets[i].AZ == report.Subnets[j].AZ && report.Subnets[i].CIDR < report.Subnets[j].CIDR)
+	})
+	return report, nil
+}
+
+func subnetUsage(subnet *types.Subnet, enis []types.NetworkInterface) (SubnetUsage, error) {
+	prefix, err := netip.ParsePrefix(*subnet.CidrBlock)
+	if err != nil {
+		return SubnetUsage{}, fmt.Errorf("invalid subnet CIDR %s: %w", *subnet.CidrBlock, err)
+	}
+	usableIPs := 1<<(32-prefix.Bits()) - reservedSubnetIPs
+	available := int(lo.FromPtr(subnet.AvailableIpAddressCount))
+	usage := SubnetUsage{
+		SubnetID:     *subnet.SubnetId,
+		Name:         lo.FromPtr(nameTag(subnet.Tags)),
+		AZ:           *subnet.AvailabilityZone,
+		Type:         SubnetType(subnet),
+		CIDR:         *subnet.CidrBlock,
+		TotalIPs:     usableIPs,
+		AvailableIPs: available,
+		UsedIPs:      usableIPs - available,
+		Utilization:  math.Round(float64(usableIPs-available)/float64(usableIPs)*1000) / 10,
+	}
+	byRequester := lo.GroupBy(enis, func(eni types.NetworkInterface) [2]string {
+		return [2]string{string(eni.InterfaceType), lo.FromPtr(eni.RequesterId)}
+	})
+	for key, requesterENIs := range byRequester {
+		usage.ENIs = append(usage.ENIs, ENIUsage{
+			InterfaceType: key[0],
+			Requester:     key[1],
+			ENIs:          len(requesterENIs),
+			IPs: lo.SumBy(requesterENIs, func(eni types.NetworkInterface) int {
+				return len(eni.PrivateIpAddresses) + prefixDelegationIPs*len(eni.Ipv4Prefixes)
+			}),
+		})
+	}
+	sort.Slice(usage.ENIs, func(i, j int) bool {
+		if usage.ENIs[i].IPs != usage.ENIs[j].IPs {
+			return usage.ENIs[i].IPs > usage.ENIs[j].IPs
+		}
+		return usage.ENIs[i].InterfaceType+usage.ENIs[i].Requester < usage.ENIs[j].InterfaceType+usage.ENIs[j].Requester
+	})
+	return usage, nil
+}
@@ -17,21 +17,51 @@ package vpc
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
-	"log"
+	"net/netip"
+	"path"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/samber/lo"
 	"gopkg.in/yaml.v3"
 )
 
 const (
-	SubnetTypePublic  = "PUBLIC"
-	SubnetTypePrivate = "PRIVATE"
-	CreatedByTagKey   = "CreatedBy"
-	CreatedByTagValue = "vpcctl"
+	SubnetTypePublic         = "PUBLIC"
+	SubnetTypePrivate        = "PRIVATE"
+	SubnetTypeTransitGateway = "TRANSIT_GATEWAY"
+	CreatedByTagKey          = "CreatedBy"
+	CreatedByTagValue        = "vpcctl"
+	IPAMPoolIDTagKey         = "IPAMPoolID"
+	SubnetTypeTagKey         = "Type"
+	NATInstanceTagKey        = "NATInstance"
+
+	// NATModeGateway routes private subnets through a managed NAT gateway
+	NATModeGateway = "gateway"
+	// NATModeInstance routes private subnets through a NAT instance, which is cheaper but not highly available
+	NATModeInstance = "instance"
+	// NATModeNone does not provide egress for private subnets
+	NATModeNone = "none"
+
+	defaultNATInstanceType = "t4g.nano"
+
+	// DefaultStepTimeout is how long a step waits for its resources when no step timeout is set
+	DefaultStepTimeout = 5 * time.Minute
+
+	// DefaultCIDR is the CIDR of VPCs created without a CIDR or IPAM pool
+	DefaultCIDR = "10.0.0.0/16"
 )
 
 var (
@@ -40,9 +70,79 @@ var (
 	}
 )
 
+// ec2API is the part of the EC2 client that vpcctl uses, so that the lookups can be tested with a fake client
+type ec2API interface {
+	AcceptVpcPeeringConnection(context.Context, *ec2.AcceptVpcPeeringConnectionInput, ...func(*ec2.Options)) (*ec2.AcceptVpcPeeringConnectionOutput, error)
+	AllocateAddress(context.Context, *ec2.AllocateAddressInput, ...func(*ec2.Options)) (*ec2.AllocateAddressOutput, error)
+	AssociateDhcpOptions(context.Context, *ec2.AssociateDhcpOptionsInput, ...func(*ec2.Options)) (*ec2.AssociateDhcpOptionsOutput, error)
+	AssociateRouteTable(context.Context, *ec2.AssociateRouteTableInput, ...func(*ec2.Options)) (*ec2.AssociateRouteTableOutput, error)
+	AttachInternetGateway(context.Context, *ec2.AttachInternetGatewayInput, ...func(*ec2.Options)) (*ec2.AttachInternetGatewayOutput, error)
+	AuthorizeSecurityGroupEgress(context.Context, *ec2.AuthorizeSecurityGroupEgressInput, ...func(*ec2.Options)) (*ec2.AuthorizeSecurityGroupEgressOutput, error)
+	AuthorizeSecurityGroupIngress(context.Context, *ec2.AuthorizeSecurityGroupIngressInput, ...func(*ec2.Options)) (*ec2.AuthorizeSecurityGroupIngressOutput, error)
+	CreateDhcpOptions(context.Context, *ec2.CreateDhcpOptionsInput, ...func(*ec2.Options)) (*ec2.CreateDhcpOptionsOutput, error)
+	CreateInternetGateway(context.Context, *ec2.CreateInternetGatewayInput, ...func(*ec2.Options)) (*ec2.CreateInternetGatewayOutput, error)
+	CreateNatGateway(context.Context, *ec2.CreateNatGatewayInput, ...func(*ec2.Options)) (*ec2.CreateNatGatewayOutput, error)
+	CreateNetworkAcl(context.Context, *ec2.CreateNetworkAclInput, ...func(*ec2.Options)) (*ec2.CreateNetworkAclOutput, error)
+	CreateNetworkAclEntry(context.Context, *ec2.CreateNetworkAclEntryInput, ...func(*ec2.Options)) (*ec2.CreateNetworkAclEntryOutput, error)
+	CreateRoute(context.Context, *ec2.CreateRouteInput, ...func(*ec2.Options)) (*ec2.CreateRouteOutput, error)
+	CreateRouteTable(context.Context, *ec2.CreateRouteTableInput, ...func(*ec2.Options)) (*ec2.CreateRouteTableOutput, error)
+	CreateSecurityGroup(context.Context, *ec2.CreateSecurityGroupInput, ...func(*ec2.Options)) (*ec2.CreateSecurityGroupOutput, error)
+	CreateSubnet(context.Context, *ec2.CreateSubnetInput, ...func(*ec2.Options)) (*ec2.CreateSubnetOutput, error)
+	CreateTags(context.Context, *ec2.CreateTagsInput, ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error)
+	CreateTransitGatewayVpcAttachment(context.Context, *ec2.CreateTransitGatewayVpcAttachmentInput, ...func(*ec2.Options)) (*ec2.CreateTransitGatewayVpcAttachmentOutput, error)
+	CreateVpc(context.Context, *ec2.CreateVpcInput, ...func(*ec2.Options)) (*ec2.CreateVpcOutput, error)
+	CreateVpcPeeringConnection(context.Context, *ec2.CreateVpcPeeringConnectionInput, ...func(*ec2.Options)) (*ec2.CreateVpcPeeringConnectionOutput, error)
+	DeleteDhcpOptions(context.Context, *ec2.DeleteDhcpOptionsInput, ...func(*ec2.Options)) (*ec2.DeleteDhcpOptionsOutput, error)
+	DeleteInternetGateway(context.Context, *ec2.DeleteInternetGatewayInput, ...func(*ec2.Options)) (*ec2.DeleteInternetGatewayOutput, error)
+	DeleteNatGateway(context.Context, *ec2.DeleteNatGatewayInput, ...func(*ec2.Options)) (*ec2.DeleteNatGatewayOutput, error)
+	DeleteNetworkAcl(context.Context, *ec2.DeleteNetworkAclInput, ...func(*ec2.Options)) (*ec2.DeleteNetworkAclOutput, error)
+	DeleteRoute(context.Context, *ec2.DeleteRouteInput, ...func(*ec2.Options)) (*ec2.DeleteRouteOutput, error)
+	DeleteRouteTable(context.Context, *ec2.DeleteRouteTableInput, ...func(*ec2.Options)) (*ec2.DeleteRouteTableOutput, error)
+	DeleteSecurityGroup(context.Context, *ec2.DeleteSecurityGroupInput, ...func(*ec2.Options)) (*ec2.DeleteSecurityGroupOutput, error)
+	DeleteSubnet(context.Context, *ec2.DeleteSubnetInput, ...func(*ec2.Options)) (*ec2.DeleteSubnetOutput, error)
+	DeleteTransitGatewayVpcAttachment(context.Context, *ec2.DeleteTransitGatewayVpcAttachmentInput, ...func(*ec2.Options)) (*ec2.DeleteTransitGatewayVpcAttachmentOutput, error)
+	DeleteVpc(context.Context, *ec2.DeleteVpcInput, ...func(*ec2.Options)) (*ec2.DeleteVpcOutput, error)
+	DeleteVpcPeeringConnection(context.Context, *ec2.DeleteVpcPeeringConnectionInput, ...func(*ec2.Options)) (*ec2.DeleteVpcPeeringConnectionOutput, error)
+	DescribeAddresses(context.Context, *ec2.DescribeAddressesInput, ...func(*ec2.Options)) (*ec2.DescribeAddressesOutput, error)
+	DescribeAvailabilityZones(context.Context, *ec2.DescribeAvailabilityZonesInput, ...func(*ec2.Options)) (*ec2.DescribeAvailabilityZonesOutput, error)
+	DescribeDhcpOptions(context.Context, *ec2.DescribeDhcpOptionsInput, ...func(*ec2.Options)) (*ec2.DescribeDhcpOptionsOutput, error)
+	DescribeFlowLogs(context.Context, *ec2.DescribeFlowLogsInput, ...func(*ec2.Options)) (*ec2.DescribeFlowLogsOutput, error)
+	DescribeImages(context.Context, *ec2.DescribeImagesInput, ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error)
+	DescribeInstanceTypes(context.Context, *ec2.DescribeInstanceTypesInput, ...func(*ec2.Options)) (*ec2.DescribeInstanceTypesOutput, error)
+	DescribeInstances(context.Context, *ec2.DescribeInstancesInput, ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error)
+	DescribeInternetGateways(context.Context, *ec2.DescribeInternetGatewaysInput, ...func(*ec2.Options)) (*ec2.DescribeInternetGatewaysOutput, error)
+	DescribeNatGateways(context.Context, *ec2.DescribeNatGatewaysInput, ...func(*ec2.Options)) (*ec2.DescribeNatGatewaysOutput, error)
+	DescribeNetworkAcls(context.Context, *ec2.DescribeNetworkAclsInput, ...func(*ec2.Options)) (*ec2.DescribeNetworkAclsOutput, error)
+	DescribeNetworkInterfaces(context.Context, *ec2.DescribeNetworkInterfacesInput, ...func(*ec2.Options)) (*ec2.DescribeNetworkInterfacesOutput, error)
+	DescribeRouteTables(context.Context, *ec2.DescribeRouteTablesInput, ...func(*ec2.Options)) (*ec2.DescribeRouteTablesOutput, error)
+	DescribeSecurityGroups(context.Context, *ec2.DescribeSecurityGroupsInput, ...func(*ec2.Options)) (*ec2.DescribeSecurityGroupsOutput, error)
+	DescribeSubnets(context.Context, *ec2.DescribeSubnetsInput, ...func(*ec2.Options)) (*ec2.DescribeSubnetsOutput, error)
+	DescribeTransitGatewayVpcAttachments(context.Context, *ec2.DescribeTransitGatewayVpcAttachmentsInput, ...func(*ec2.Options)) (*ec2.DescribeTransitGatewayVpcAttachmentsOutput, error)
+	DescribeVpcAttribute(context.Context, *ec2.DescribeVpcAttributeInput, ...func(*ec2.Options)) (*ec2.DescribeVpcAttributeOutput, error)
+	DescribeVpcEndpoints(context.Context, *ec2.DescribeVpcEndpointsInput, ...func(*ec2.Options)) (*ec2.DescribeVpcEndpointsOutput, error)
+	DescribeVpcPeeringConnections(context.Context, *ec2.DescribeVpcPeeringConnectionsInput, ...func(*ec2.Options)) (*ec2.DescribeVpcPeeringConnectionsOutput, error)
+	DescribeVpcs(context.Context, *ec2.DescribeVpcsInput, ...func(*ec2.Options)) (*ec2.DescribeVpcsOutput, error)
+	DetachInternetGateway(context.Context, *ec2.DetachInternetGatewayInput, ...func(*ec2.Options)) (*ec2.DetachInternetGatewayOutput, error)
+	DisassociateRouteTable(context.Context, *ec2.DisassociateRouteTableInput, ...func(*ec2.Options)) (*ec2.DisassociateRouteTableOutput, error)
+	ModifyInstanceAttribute(context.Context, *ec2.ModifyInstanceAttributeInput, ...func(*ec2.Options)) (*ec2.ModifyInstanceAttributeOutput, error)
+	ModifySubnetAttribute(context.Context, *ec2.ModifySubnetAttributeInput, ...func(*ec2.Options)) (*ec2.ModifySubnetAttributeOutput, error)
+	ModifyVpcAttribute(context.Context, *ec2.ModifyVpcAttributeInput, ...func(*ec2.Options)) (*ec2.ModifyVpcAttributeOutput, error)
+	ReleaseAddress(context.Context, *ec2.ReleaseAddressInput, ...func(*ec2.Options)) (*ec2.ReleaseAddressOutput, error)
+	ReplaceNetworkAclAssociation(context.Context, *ec2.ReplaceNetworkAclAssociationInput, ...func(*ec2.Options)) (*ec2.ReplaceNetworkAclAssociationOutput, error)
+	ReplaceRoute(context.Context, *ec2.ReplaceRouteInput, ...func(*ec2.Options)) (*ec2.ReplaceRouteOutput, error)
+	RevokeSecurityGroupEgress(context.Context, *ec2.RevokeSecurityGroupEgressInput, ...func(*ec2.Options)) (*ec2.RevokeSecurityGroupEgressOutput, error)
+	RevokeSecurityGroupIngress(context.Context, *ec2.RevokeSecurityGroupIngressInput, ...func(*ec2.Options)) (*ec2.RevokeSecurityGroupIngressOutput, error)
+	RunInstances(context.Context, *ec2.RunInstancesInput, ...func(*ec2.Options)) (*ec2.RunInstancesOutput, error)
+	TerminateInstances(context.Context, *ec2.TerminateInstancesInput, ...func(*ec2.Options)) (*ec2.TerminateInstancesOutput, error)
+}
+
 type Client struct {
-	cfg       aws.Config
-	ec2Client *ec2.Client
+	cfg           aws.Config
+	ec2Client     ec2API
+	route53Client *route53.Client
+	quotasClient  *servicequotas.Client
+	// observer receives the events of operations, they are logged when it is nil
+	observer Observer
 }
 
 type CreateOptions struct {
@@ -50,15 +150,103 @@ type CreateOptions struct {
 	CIDR    string
 	Subnets []CreateSubnetOptions
 	Tags    map[string]string
+	// IPAMPoolID allocates the VPC CIDR from an IPAM pool instead of using CIDR
+	IPAMPoolID string
+	// IPAMNetmaskLength is the netmask length of the CIDR allocated from IPAMPoolID.
+	// The pool's default netmask length is used when this is 0.
+	IPAMNetmaskLength int32
+	SecurityGroups    []CreateSecurityGroupOptions
+	NetworkACLs       []CreateNetworkACLOptions
+	// RestrictDefaultSecurityGroup removes all rules from the VPC's default security group
+	RestrictDefaultSecurityGroup bool
+	// TransitGateway attaches the VPC to a transit gateway when set
+	TransitGateway *CreateTransitGatewayAttachmentOptions
+	// NAT is the NATMode* used for private subnet egress, defaults to NATModeGateway
+	NAT string
+	// NATInstanceType is the instance type of the NAT instance, defaults to t4g.nano
+	NATInstanceType string
+	// NATInstanceAMI is the AMI of the NAT instance, defaults to the latest Amazon Linux 2023 AMI
+	NATInstanceAMI string
+	// EnableDNSSupport enables the Amazon provided DNS server, defaults to true
+	EnableDNSSupport *bool
+	// EnableDNSHostnames assigns public DNS hostnames to instances, defaults to true
+	EnableDNSHostnames *bool
+	// DHCPOptions creates and associates a custom DHCP options set when set
+	DHCPOptions *CreateDHCPOptions
+	// PrivateHostedZone is the domain name of a Route 53 private hosted zone associated with the VPC
+	PrivateHostedZone string
+	// Resume keeps the resources of an existing VPC named Name and only creates the missing ones
+	Resume bool
+	// ResumeID resumes the VPC with the ID instead of the VPC named Name
+	ResumeID string
+	// SkipPreflight skips checking the quotas and the CIDR overlap with the region's VPCs before creating any resources
+	SkipPreflight bool
+	// Timeout cancels the create when it takes longer, there is no overall timeout when it is 0
+	Timeout time.Duration
+	// StepTimeout is the longest a step waits for its resources to become available, defaults to DefaultStepTimeout
+	StepTimeout time.Duration
+}
+
+// CreateDHCPOptions is a custom DHCP options set for the VPC
+type CreateDHCPOptions struct {
+	DomainName string
+	// DNSServers defaults to AmazonProvidedDNS
+	DNSServers []string
+	NTPServers []string
+}
+
+// CreateTransitGatewayAttachmentOptions attaches the VPC to an existing transit gateway
+type CreateTransitGatewayAttachmentOptions struct {
+	TransitGatewayID string
+	// Tier is the PUBLIC or PRIVATE subnets to attach from, one subnet per AZ is used. Defaults to PRIVATE.
+	Tier string
+	// DedicatedSubnets creates a /28 attachment subnet per AZ at the end of the VPC CIDR instead of using the Tier subnets
+	DedicatedSubnets bool
+	// Routes are the destination CIDRs routed to the transit gateway from all vpcctl route tables
+	Routes []string
 }
 
 type DeleteOptions struct {
-	Name                   string
+	Name string
+	// ID selects the VPC by ID instead of by name
+	ID string
+	// Tags selects the VPC by tags, all of the tags have to match
+	Tags                   map[string]string
 	DeleteUnownedResources bool
+	// ResourceIDs deletes the resources by ID instead of looking them up by the vpcctl tags, e.g. from a journal
+	// when the tags were lost
+	ResourceIDs []string
+	// Timeout cancels the delete when it takes longer, there is no overall timeout when it is 0
+	Timeout time.Duration
+	// StepTimeout is the longest a step waits for its resources to be deleted, defaults to DefaultStepTimeout
+	StepTimeout time.Duration
 }
 
+// GetOptions selects a single VPC created by vpcctl by any combination of Name, ID and Tags.
+// When multiple VPCs match, an error listing the candidates is returned.
 type GetOptions struct {
 	Name string
+	// ID selects the VPC by ID instead of by name
+	ID string
+	// Tags selects the VPC by tags, all of the tags have to match
+	Tags map[string]string
+	// ResourceIDs selects the VPC and its resources by ID instead of by the vpcctl tags, e.g. from a journal
+	ResourceIDs []string
+}
+
+func (o GetOptions) String() string {
+	return strings.Join(lo.Compact([]string{o.ID, o.Name, formatSelector(o.Tags)}), " ")
+}
+
+// ListOptions selects VPCs created by vpcctl by a Name glob (e.g. dev-*) and Tags
+type ListOptions struct {
+	Name string
+	Tags map[string]string
+}
+
+// IsGlob returns true when the name contains glob patterns
+func IsGlob(name string) bool {
+	return strings.ContainsAny(name, "*?[")
 }
 
 type CreateSubnetOptions struct {
@@ -67,180 +255,597 @@ type CreateSubnetOptions struct {
 	Public bool
 }
 
+// CreateSecurityGroupOptions defines a security group managed by vpcctl
+type CreateSecurityGroupOptions struct {
+	Name        string
+	Description string
+	Ingress     []SecurityGroupRuleOptions
+	// Egress replaces the default allow all egress rule when set
+	Egress []SecurityGroupRuleOptions
+}
+
+// SecurityGroupRuleOptions is a single security group rule.
+// Exactly one of CIDR, PrefixListID, Tier or SecurityGroup should be set as the source or destination.
+type SecurityGroupRuleOptions struct {
+	// Protocol is tcp, udp, icmp, all, or an IP protocol number
+	Protocol     string
+	FromPort     int32
+	ToPort       int32
+	CIDR         string
+	PrefixListID string
+	// Tier refers to the CIDRs of all PUBLIC or PRIVATE subnets in the VPC
+	Tier string
+	// SecurityGroup refers to another managed security group by name or a security group ID
+	SecurityGroup string
+	Description   string
+}
+
+// CreateNetworkACLOptions defines a network ACL managed by vpcctl
+type CreateNetworkACLOptions struct {
+	Name string
+	// Tier is the PUBLIC or PRIVATE subnets to associate the network ACL with, all subnets if empty
+	Tier    string
+	Ingress []NetworkACLRuleOptions
+	Egress  []NetworkACLRuleOptions
+}
+
+// NetworkACLRuleOptions is a single network ACL entry.
+// Exactly one of CIDR or Tier should be set.
+type NetworkACLRuleOptions struct {
+	// RuleNumber is assigned in increments of 10 starting at 100 when 0. A tier rule uses consecutive rule numbers
+	// starting at RuleNumber, one per subnet of the tier.
+	RuleNumber int32
+	// Protocol is tcp, udp, icmp, all, or an IP protocol number
+	Protocol string
+	FromPort int32
+	ToPort   int32
+	CIDR     string
+	// Tier refers to the CIDRs of all PUBLIC or PRIVATE subnets in the VPC
+	Tier string
+	// Action is allow or deny, defaults to allow
+	Action string
+}
+
 type Details struct {
 	VPC             *types.Vpc
 	Subnets         []*types.Subnet
 	RouteTables     []*types.RouteTable
 	InternetGateway *types.InternetGateway
-	NATGateway      *types.NatGateway
+	// NATGateways are the pending and available NAT gateways
+	NATGateways []*types.NatGateway
+	// NATGateway is the first of NATGateways.
+	//
+	// Deprecated: Use NATGateways. NATGateway is kept for consumers of the JSON output.
+	NATGateway     *types.NatGateway
+	NATInstance    *types.Instance
+	SecurityGroups []*types.SecurityGroup
+	NetworkACLs    []*types.NetworkAcl
+	// PeeringConnections are the live peering connections where the VPC is the requester or accepter
+	PeeringConnections       []*types.VpcPeeringConnection
+	TransitGatewayAttachment *types.TransitGatewayVpcAttachment
+	EnableDNSSupport         bool
+	EnableDNSHostnames       bool
+	// DHCPOptions is only set for DHCP options sets created by vpcctl
+	DHCPOptions *types.DhcpOptions
+	HostedZone  *route53types.HostedZone
+	// Diagnostics is only set when there are resources that need attention
+	Diagnostics *Diagnostics `json:",omitempty"`
+}
+
+// Diagnostics are resources created by vpcctl that are not live, so they are left out of the rest of the details.
+// They are still cleaned up when the VPC is deleted.
+type Diagnostics struct {
+	// NATGateways are NAT gateways that failed, with the reason in FailureMessage, or that are being deleted
+	NATGateways []*types.NatGateway
 }
 
 func New(cfg aws.Config) *Client {
 	return &Client{
-		cfg:       cfg,
-		ec2Client: ec2.NewFromConfig(cfg),
+		cfg:           cfg,
+		ec2Client:     ec2.NewFromConfig(cfg),
+		route53Client: route53.NewFromConfig(cfg),
+		quotasClient:  servicequotas.NewFromConfig(cfg),
+	}
+}
+
+// targetClient returns a client for another region and/or account.
+// An empty region keeps the client's region and an empty roleARN keeps the client's credentials.
+func (v Client) targetClient(region string, roleARN string) Client {
+	cfg := v.cfg.Copy()
+	if region != "" {
+		cfg.Region = region
+	}
+	if roleARN != "" {
+		cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(sts.NewFromConfig(v.cfg), roleARN))
 	}
+	return *New(cfg).WithObserver(v.observer)
 }
 
-// DefaultSubnets uses 3 subnets in the region with
+// DefaultSubnets uses 3 subnets in the region carved from the VPC CIDR.
+// For a /16 VPC CIDR this results in:
 // Private /18 CIDRs (16,382 IPs)
 // Public /20 CIDRs (4,094 IPs)
-func DefaultSubnets(region string) []CreateSubnetOptions {
-	return []CreateSubnetOptions{
-		{
-			AZ:     fmt.Sprintf("%sa", region),
-			CIDR:   "10.0.0.0/18",
-			Public: false,
-		},
-		{
-			AZ:     fmt.Sprintf("%sb", region),
-			CIDR:   "10.0.64.0/18",
-			Public: false,
-		},
-		{
-			AZ:     fmt.Sprintf("%sc", region),
-			CIDR:   "10.0.128.0/18",
+func DefaultSubnets(region string, vpcCIDR string) ([]CreateSubnetOptions, error) {
+	prefix, err := netip.ParsePrefix(vpcCIDR)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VPC CIDR %s: %w", vpcCIDR, err)
+	}
+	// The VPC CIDR is split into quarters, the first 3 are used for private subnets
+	// and the last quarter is split again into quarters for the public subnets.
+	privateCIDRs, err := splitCIDR(prefix.Masked(), 2)
+	if err != nil {
+		return nil, err
+	}
+	publicCIDRs, err := splitCIDR(privateCIDRs[3], 2)
+	if err != nil {
+		return nil, err
+	}
+	var subnets []CreateSubnetOptions
+	for i, az := range []string{"a", "b", "c"} {
+		subnets = append(subnets, CreateSubnetOptions{
+			AZ:     fmt.Sprintf("%s%s", region, az),
+			CIDR:   privateCIDRs[i].String(),
 			Public: false,
-		},
-		{
-			AZ:     fmt.Sprintf("%sa", region),
-			CIDR:   "10.0.192.0/20",
-			Public: true,
-		},
-		{
-			AZ:     fmt.Sprintf("%sb", region),
-			CIDR:   "10.0.208.0/20",
-			Public: true,
-		},
-		{
-			AZ:     fmt.Sprintf("%sc", region),
-			CIDR:   "10.0.224.0/20",
+		})
+	}
+	for i, az := range []string{"a", "b", "c"} {
+		subnets = append(subnets, CreateSubnetOptions{
+			AZ:     fmt.Sprintf("%s%s", region, az),
+			CIDR:   publicCIDRs[i].String(),
 			Public: true,
-		},
+		})
 	}
+	return subnets, nil
 }
 
-func (v Client) List(ctx context.Context) ([]string, error) {
-	vpcOut, err := v.ec2Client.DescribeVpcs(ctx, &ec2.DescribeVpcsInput{
-		Filters: []types.Filter{
-			{
-				Name:   aws.String(fmt.Sprintf("tag:%s", CreatedByTagKey)),
-				Values: []string{CreatedByTagValue},
-			},
-			{
-				Name:   aws.String("tag-key"),
-				Values: []string{"Name"},
-			},
-		},
-	})
+// splitCIDR splits an IPv4 prefix into 2^bits equally sized prefixes
+func splitCIDR(prefix netip.Prefix, bits int) ([]netip.Prefix, error) {
+	newBits := prefix.Bits() + bits
+	// 28 is the smallest subnet size allowed in a VPC
+	if !prefix.Addr().Is4() || newBits > 28 {
+		return nil, fmt.Errorf("unable to split %s into /%d subnets", prefix, newBits)
+	}
+	size := uint32(1) << (32 - newBits)
+	start := binary.BigEndian.Uint32(prefix.Addr().AsSlice())
+	var prefixes []netip.Prefix
+	for i := uint32(0); i < 1<<bits; i++ {
+		var addr [4]byte
+		binary.BigEndian.PutUint32(addr[:], start+i*size)
+		prefixes = append(prefixes, netip.PrefixFrom(netip.AddrFrom4(addr), newBits))
+	}
+	return prefixes, nil
+}
+
+// List returns the names of the VPCs created by vpcctl that match the options
+func (v Client) List(ctx context.Context, opts ListOptions) ([]string, error) {
+	vpcs, err := v.Find(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
-	return lo.Compact(lo.Map(vpcOut.Vpcs, func(vpc types.Vpc, _ int) string {
-		if tag, ok := lo.Find(vpc.Tags, func(tag types.Tag) bool { return *tag.Key == "Name" }); ok {
-			return *tag.Value
+	return lo.Map(vpcs, func(vpc types.Vpc, _ int) string { return *nameTag(vpc.Tags) }), nil
+}
+
+// Find returns the named VPCs created by vpcctl that match the options
+func (v Client) Find(ctx context.Context, opts ListOptions) ([]types.Vpc, error) {
+	vpcs, err := v.findVPCs(ctx, "", opts.Tags)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Filter(vpcs, func(vpc types.Vpc, _ int) bool {
+		name := nameTag(vpc.Tags)
+		if name == nil {
+			return false
+		}
+		if opts.Name == "" {
+			return true
 		}
-		return ""
-	})), nil
+		matched, err := path.Match(opts.Name, *name)
+		return err == nil && matched
+	}), nil
 }
 
+// Create creates a VPC and its subresources. When Resume is set and the VPC already exists, the resources that
+// exist are kept and only the missing ones are created, which continues a create that was interrupted or timed out.
 func (v Client) Create(ctx context.Context, opts CreateOptions) (*Details, error) {
+	ctx, cancel := withTimeout(ctx, opts.Timeout)
+	defer cancel()
+	vpcDetails, err := v.create(ctx, opts)
+	return vpcDetails, interrupted(ctx, err)
+}
+
+func (v Client) create(ctx context.Context, opts CreateOptions) (*Details, error) {
 	vpcDetails := &Details{}
-	if len(opts.Subnets) == 0 {
-		opts.Subnets = DefaultSubnets(v.cfg.Region)
+	if opts.Resume {
+		existing, err := v.resumeDetails(ctx, opts.Name, opts.ResumeID)
+		if err != nil {
+			return existing, err
+		}
+		vpcDetails = existing
 	}
-	log.Printf("Creating VPC %s", opts.Name)
-	vpc, err := v.createVPC(ctx, opts)
-	vpcDetails.VPC = vpc
-	if err != nil {
+	v.planCreate(opts)
+	if err := validateTGWSubnets(vpcDetails, v.cfg.Region, opts); err != nil {
 		return vpcDetails, err
 	}
-	log.Printf("Created VPC %s", *vpc.VpcId)
-
-	log.Println("Creating Subnets")
-	subnets, err := v.createSubnets(ctx, *vpc.VpcId, opts)
-	vpcDetails.Subnets = subnets
+	if !opts.SkipPreflight {
+		if err := v.preflight(ctx, vpcDetails, opts); err != nil {
+			return vpcDetails, err
+		}
+	}
+	routeTables, err := v.createNetwork(ctx, vpcDetails, opts)
 	if err != nil {
 		return vpcDetails, err
 	}
-	privateSubnets := lo.Filter(subnets, func(subnet *types.Subnet, _ int) bool { return !*subnet.MapPublicIpOnLaunch })
-	publicSubnets := lo.Filter(subnets, func(subnet *types.Subnet, _ int) bool { return *subnet.MapPublicIpOnLaunch })
-	log.Printf("Created Public Subnets %s", lo.Map(publicSubnets, func(subnet *types.Subnet, _ int) string { return *subnet.SubnetId }))
-	log.Printf("Created Private Subnets %s", lo.Map(privateSubnets, func(subnet *types.Subnet, _ int) string { return *subnet.SubnetId }))
+	subnets := lo.Filter(vpcDetails.Subnets, func(subnet *types.Subnet, _ int) bool { return SubnetType(subnet) != SubnetTypeTransitGateway })
 
-	log.Println("Creating Route Tables")
-	routeTables, err := v.createRouteTables(ctx, subnets, opts)
-	vpcDetails.RouteTables = lo.Values(routeTables)
-	if err != nil {
+	if err := v.createNAT(ctx, vpcDetails, routeTables[SubnetTypePrivate], opts); err != nil {
 		return vpcDetails, err
 	}
-	log.Printf("Created Route Tables: %s", lo.Map(vpcDetails.RouteTables, func(rt *types.RouteTable, _ int) string { return *rt.RouteTableId }))
 
-	log.Println("Creating Internet Gateway")
-	igw, err := v.createIGW(ctx, *vpc.VpcId, routeTables[SubnetTypePublic], opts)
-	vpcDetails.InternetGateway = igw
-	if err != nil {
-		return vpcDetails, err
+	if opts.TransitGateway != nil && vpcDetails.TransitGatewayAttachment != nil {
+		v.exists(ActionCreate, "Transit Gateway Attachment", *vpcDetails.TransitGatewayAttachment.TransitGatewayAttachmentId)
+	} else if opts.TransitGateway != nil {
+		t := v.track(ActionCreate, "Transit Gateway Attachment", opts.TransitGateway.TransitGatewayID)
+		tgwAttachment, tgwSubnets, err := v.createTGWAttachment(ctx, vpcDetails.VPC, subnets, vpcDetails.RouteTables, opts)
+		vpcDetails.Subnets = append(vpcDetails.Subnets, tgwSubnets...)
+		vpcDetails.TransitGatewayAttachment = tgwAttachment
+		if err := t.done(err, optionalID(tgwAttachment, func(a *types.TransitGatewayVpcAttachment) *string { return a.TransitGatewayAttachmentId })...); err != nil {
+			return vpcDetails, err
+		}
 	}
-	log.Printf("Created Internet Gateway: %s", *vpcDetails.InternetGateway.InternetGatewayId)
 
-	log.Println("Creating NAT Gateway")
-	natGW, err := v.createNATGW(ctx, subnets, routeTables[SubnetTypePrivate], opts)
-	vpcDetails.NATGateway = natGW
-	if err != nil {
+	if err := v.createNetworkSecurity(ctx, vpcDetails, subnets, opts); err != nil {
 		return vpcDetails, err
 	}
-	if natGW != nil {
-		log.Printf("Created NAT Gateway: %s", *vpcDetails.NATGateway.NatGatewayId)
-	} else {
-		log.Print("Skipping NAT Gateway")
+
+	if opts.PrivateHostedZone != "" && vpcDetails.HostedZone != nil {
+		v.exists(ActionCreate, "Private Hosted Zone", *vpcDetails.HostedZone.Id)
+	} else if opts.PrivateHostedZone != "" {
+		t := v.track(ActionCreate, "Private Hosted Zone", opts.PrivateHostedZone)
+		hostedZone, err := v.createHostedZone(ctx, *vpcDetails.VPC.VpcId, opts)
+		vpcDetails.HostedZone = hostedZone
+		if err := t.done(err, optionalID(hostedZone, func(hz *route53types.HostedZone) *string { return hz.Id })...); err != nil {
+			return vpcDetails, err
+		}
 	}
 	return vpcDetails, nil
 }
 
-func (v Client) Delete(ctx context.Context, opts DeleteOptions) (*Details, error) {
-	log.Printf("Fetching VPC details for %s", opts.Name)
-	vpcDetails, err := v.Get(ctx, GetOptions{Name: opts.Name})
-	if err != nil {
-		return vpcDetails, err
+// resumeDetails returns the details of the VPC with the ID, or named name when there is no ID, when it exists,
+// otherwise the details are empty
+func (v Client) resumeDetails(ctx context.Context, name string, id string) (*Details, error) {
+	getOpts := GetOptions{ID: id}
+	if id == "" {
+		getOpts = GetOptions{Name: name}
 	}
-	if vpcDetails.NATGateway != nil {
-		log.Printf("Deleting NAT Gateway %s", *vpcDetails.NATGateway.NatGatewayId)
-		if err := v.deleteNATGW(ctx, vpcDetails, opts); err != nil {
-			return vpcDetails, err
+	vpcs, err := v.findVPCs(ctx, getOpts.ID, lo.Ternary(id == "", map[string]string{"Name": name}, nil))
+	if err != nil || len(vpcs) == 0 {
+		return &Details{}, err
+	}
+	t := v.track(ActionFetch, "VPC details", lo.CoalesceOrEmpty(id, name))
+	vpcDetails, err := v.Get(ctx, getOpts)
+	return vpcDetails, t.done(err)
+}
+
+// createNetwork creates the VPC, its subnets, route tables and internet gateway that don't exist yet
+// and returns the route tables by subnet type
+func (v Client) createNetwork(ctx context.Context, vpcDetails *Details, opts CreateOptions) (map[string]*types.RouteTable, error) {
+	if vpcDetails.VPC != nil {
+		v.exists(ActionCreate, "VPC", *vpcDetails.VPC.VpcId)
+	} else {
+		t := v.track(ActionCreate, "VPC", opts.Name)
+		vpc, err := v.createVPC(ctx, opts)
+		vpcDetails.VPC = vpc
+		if err := t.done(err, optionalID(vpc, func(vpc *types.Vpc) *string { return vpc.VpcId })...); err != nil {
+			return nil, err
+		}
+	}
+	vpcID := *vpcDetails.VPC.VpcId
+
+	if err := v.configureDNS(ctx, vpcDetails, opts); err != nil {
+		return nil, err
+	}
+
+	if len(opts.Subnets) == 0 {
+		var err error
+		opts.Subnets, err = DefaultSubnets(v.cfg.Region, *vpcDetails.VPC.CidrBlock)
+		if err != nil {
+			return nil, err
+		}
+	}
+	missingSubnets := lo.Reject(opts.Subnets, func(subnetOpts CreateSubnetOptions, _ int) bool {
+		return lo.ContainsBy(vpcDetails.Subnets, func(subnet *types.Subnet) bool { return lo.FromPtr(subnet.CidrBlock) == subnetOpts.CIDR })
+	})
+	if len(missingSubnets) == 0 {
+		v.exists(ActionCreate, "Subnets", lo.Map(vpcDetails.Subnets, func(subnet *types.Subnet, _ int) string { return *subnet.SubnetId })...)
+	} else {
+		t := v.track(ActionCreate, "Subnets")
+		subnetOpts := opts
+		subnetOpts.Subnets = missingSubnets
+		subnets, err := v.createSubnets(ctx, vpcID, subnetOpts)
+		vpcDetails.Subnets = append(vpcDetails.Subnets, subnets...)
+		if err := t.done(err, lo.Map(subnets, func(subnet *types.Subnet, _ int) string { return *subnet.SubnetId })...); err != nil {
+			return nil, err
 		}
-		log.Printf("Deleted NAT Gateway %s", *vpcDetails.NATGateway.NatGatewayId)
 	}
+	subnets := lo.Filter(vpcDetails.Subnets, func(subnet *types.Subnet, _ int) bool { return SubnetType(subnet) != SubnetTypeTransitGateway })
+
+	t := v.track(ActionCreate, "Route Tables")
+	routeTables, err := v.createRouteTables(ctx, subnets, routeTablesByType(vpcDetails), opts)
+	vpcDetails.RouteTables = lo.Uniq(append(vpcDetails.RouteTables, lo.Values(routeTables)...))
+	if err := t.done(err, lo.Map(vpcDetails.RouteTables, func(rt *types.RouteTable, _ int) string { return *rt.RouteTableId })...); err != nil {
+		return nil, err
+	}
+
 	if vpcDetails.InternetGateway != nil {
-		log.Printf("Deleting Internet Gateway %s", *vpcDetails.InternetGateway.InternetGatewayId)
-		if err := v.deleteIGW(ctx, vpcDetails, opts); err != nil {
-			return vpcDetails, err
+		v.exists(ActionCreate, "Internet Gateway", *vpcDetails.InternetGateway.InternetGatewayId)
+		return routeTables, nil
+	}
+	t = v.track(ActionCreate, "Internet Gateway")
+	igw, err := v.createIGW(ctx, vpcID, routeTables[SubnetTypePublic], opts)
+	vpcDetails.InternetGateway = igw
+	return routeTables, t.done(err, optionalID(igw, func(igw *types.InternetGateway) *string { return igw.InternetGatewayId })...)
+}
+
+// routeTablesByType returns the route tables of the VPC by the type of the subnets associated with them
+func routeTablesByType(vpcDetails *Details) map[string]*types.RouteTable {
+	routeTables := map[string]*types.RouteTable{}
+	for _, rt := range vpcDetails.RouteTables {
+		for _, association := range rt.Associations {
+			subnet, ok := lo.Find(vpcDetails.Subnets, func(subnet *types.Subnet) bool { return *subnet.SubnetId == lo.FromPtr(association.SubnetId) })
+			if ok && (SubnetType(subnet) == SubnetTypePublic || SubnetType(subnet) == SubnetTypePrivate) {
+				routeTables[SubnetType(subnet)] = rt
+			}
 		}
-		log.Printf("Deleted Internet Gateway %s", *vpcDetails.InternetGateway.InternetGatewayId)
 	}
-	if len(vpcDetails.RouteTables) != 0 {
-		log.Printf("Deleting Route Tables %v", lo.Map(vpcDetails.RouteTables, func(rt *types.RouteTable, _ int) string { return *rt.RouteTableId }))
-		if err := v.deleteRouteTables(ctx, vpcDetails, opts); err != nil {
-			return vpcDetails, err
+	return routeTables
+}
+
+// planCreate emits pending events for the steps that Create runs with the options, in the order they run
+func (v Client) planCreate(opts CreateOptions) {
+	if !opts.SkipPreflight {
+		v.plan(ActionCheck, "Quotas and CIDR")
+	}
+	v.plan(ActionCreate, "VPC")
+	v.plan(ActionConfigure, "VPC DNS")
+	if opts.DHCPOptions != nil {
+		v.plan(ActionCreate, "DHCP Options")
+	}
+	v.plan(ActionCreate, "Subnets", "Route Tables", "Internet Gateway")
+	switch opts.NAT {
+	case NATModeInstance:
+		v.plan(ActionCreate, "NAT Instance")
+	case NATModeGateway, "":
+		v.plan(ActionCreate, "NAT Gateway")
+	}
+	if opts.TransitGateway != nil {
+		v.plan(ActionCreate, "Transit Gateway Attachment")
+	}
+	if opts.RestrictDefaultSecurityGroup {
+		v.plan(ActionRestrict, "Default Security Group")
+	}
+	if len(opts.SecurityGroups) != 0 {
+		v.plan(ActionCreate, "Security Groups")
+	}
+	if len(opts.NetworkACLs) != 0 {
+		v.plan(ActionCreate, "Network ACLs")
+	}
+	if opts.PrivateHostedZone != "" {
+		v.plan(ActionCreate, "Private Hosted Zone")
+	}
+}
+
+// configureDNS sets the VPC DNS attributes and creates the custom DHCP options set
+func (v Client) configureDNS(ctx context.Context, vpcDetails *Details, opts CreateOptions) error {
+	vpcDetails.EnableDNSSupport = lo.FromPtrOr(opts.EnableDNSSupport, true)
+	vpcDetails.EnableDNSHostnames = lo.FromPtrOr(opts.EnableDNSHostnames, true)
+	if opts.PrivateHostedZone != "" && !(vpcDetails.EnableDNSSupport && vpcDetails.EnableDNSHostnames) {
+		return fmt.Errorf("DNS support and DNS hostnames must be enabled to use a private hosted zone")
+	}
+	t := v.track(ActionConfigure, "VPC DNS")
+	if err := t.done(v.modifyDNSAttributes(ctx, *vpcDetails.VPC.VpcId, vpcDetails.EnableDNSSupport, vpcDetails.EnableDNSHostnames)); err != nil {
+		return err
+	}
+	if opts.DHCPOptions != nil && vpcDetails.DHCPOptions != nil {
+		v.exists(ActionCreate, "DHCP Options", *vpcDetails.DHCPOptions.DhcpOptionsId)
+	} else if opts.DHCPOptions != nil {
+		t = v.track(ActionCreate, "DHCP Options")
+		dhcpOptions, err := v.createDHCPOptions(ctx, *vpcDetails.VPC.VpcId, opts)
+		vpcDetails.DHCPOptions = dhcpOptions
+		if err := t.done(err, optionalID(dhcpOptions, func(dhcp *types.DhcpOptions) *string { return dhcp.DhcpOptionsId })...); err != nil {
+			return err
 		}
-		log.Printf("Deleted Route Tables %v", lo.Map(vpcDetails.RouteTables, func(rt *types.RouteTable, _ int) string { return *rt.RouteTableId }))
 	}
-	if len(vpcDetails.Subnets) != 0 {
-		log.Printf("Deleting Subnets %v", lo.Map(vpcDetails.Subnets, func(subnet *types.Subnet, _ int) string { return *subnet.SubnetId }))
-		if err := v.deleteSubnets(ctx, vpcDetails, opts); err != nil {
-			return vpcDetails, err
+	return nil
+}
+
+// createNAT creates the egress resources for private subnets based on the NAT mode
+func (v Client) createNAT(ctx context.Context, vpcDetails *Details, routeTable *types.RouteTable, opts CreateOptions) error {
+	if len(vpcDetails.NATGateways) != 0 {
+		v.exists(ActionCreate, "NAT Gateway", lo.Map(vpcDetails.NATGateways, func(natGW *types.NatGateway, _ int) string { return *natGW.NatGatewayId })...)
+		return v.resumeNAT(ctx, vpcDetails, routeTable, opts)
+	}
+	if vpcDetails.NATInstance != nil {
+		v.exists(ActionCreate, "NAT Instance", *vpcDetails.NATInstance.InstanceId)
+		return v.resumeNAT(ctx, vpcDetails, routeTable, opts)
+	}
+	switch opts.NAT {
+	case NATModeNone:
+		v.skipped(ActionCreate, "NAT")
+	case NATModeInstance:
+		t := v.track(ActionCreate, "NAT Instance")
+		natInstance, natSG, err := v.createNATInstance(ctx, vpcDetails.VPC, vpcDetails.Subnets, routeTable, opts)
+		vpcDetails.NATInstance = natInstance
+		if natSG != nil {
+			vpcDetails.SecurityGroups = append(vpcDetails.SecurityGroups, natSG)
+		}
+		if err := t.done(err, optionalID(natInstance, func(instance *types.Instance) *string { return instance.InstanceId })...); err != nil {
+			return err
+		}
+		if natInstance == nil {
+			v.skipped(ActionCreate, "NAT Instance")
 		}
-		log.Printf("Deleted Subnets %v", lo.Map(vpcDetails.Subnets, func(subnet *types.Subnet, _ int) string { return *subnet.SubnetId }))
+	case NATModeGateway, "":
+		t := v.track(ActionCreate, "NAT Gateway")
+		natGW, err := v.createNATGW(ctx, vpcDetails.Subnets, routeTable, opts)
+		if natGW != nil {
+			vpcDetails.NATGateways = []*types.NatGateway{natGW}
+			vpcDetails.NATGateway = natGW
+		}
+		if err := t.done(err, optionalID(natGW, func(natGW *types.NatGateway) *string { return natGW.NatGatewayId })...); err != nil {
+			return err
+		}
+		if natGW == nil {
+			v.skipped(ActionCreate, "NAT Gateway")
+		}
+	default:
+		return fmt.Errorf("unknown NAT mode %q, must be one of %s, %s or %s", opts.NAT, NATModeGateway, NATModeInstance, NATModeNone)
 	}
-	if vpcDetails.VPC != nil {
-		log.Printf("Deleting VPC %s", *vpcDetails.VPC.VpcId)
-		if err := v.deleteVPC(ctx, vpcDetails, opts); err != nil {
+	return nil
+}
+
+// resumeNAT waits for the NAT gateway or instance of a resumed create to become available
+// and restores the private default route to it if a previous run stopped before routing
+func (v Client) resumeNAT(ctx context.Context, vpcDetails *Details, routeTable *types.RouteTable, opts CreateOptions) error {
+	// without private subnets there is no route table to route to the NAT
+	if routeTable == nil {
+		return nil
+	}
+	if len(vpcDetails.NATGateways) != 0 {
+		// prefer the NAT gateway that is already available over pending ones
+		natGW := lo.MinBy(vpcDetails.NATGateways, func(a *types.NatGateway, b *types.NatGateway) bool {
+			return a.State == types.NatGatewayStateAvailable && b.State != types.NatGatewayStateAvailable
+		})
+		t := v.track(ActionConfigure, "NAT Gateway Route", *natGW.NatGatewayId)
+		_, err := v.routeNATGW(ctx, natGW, routeTable, opts)
+		return t.done(err)
+	}
+	t := v.track(ActionConfigure, "NAT Instance Route", *vpcDetails.NATInstance.InstanceId)
+	return t.done(v.routeNATInstance(ctx, vpcDetails.NATInstance, routeTable, opts))
+}
+
+// createNetworkSecurity locks down the default security group and creates the managed security groups and network ACLs
+func (v Client) createNetworkSecurity(ctx context.Context, vpcDetails *Details, subnets []*types.Subnet, opts CreateOptions) error {
+	vpcID := *vpcDetails.VPC.VpcId
+	if opts.RestrictDefaultSecurityGroup {
+		t := v.track(ActionRestrict, "Default Security Group")
+		if err := t.done(v.restrictDefaultSecurityGroup(ctx, vpcID)); err != nil {
+			return err
+		}
+	}
+
+	existingGroups := lo.Filter(vpcDetails.SecurityGroups, func(sg *types.SecurityGroup, _ int) bool {
+		return lo.ContainsBy(opts.SecurityGroups, func(sgOpts CreateSecurityGroupOptions) bool {
+			return lo.FromPtr(sg.GroupName) == fmt.Sprintf("%s-%s", opts.Name, sgOpts.Name)
+		})
+	})
+	// security group rules refer to each other, so they are only created when none of the groups exist
+	if len(existingGroups) != 0 {
+		v.exists(ActionCreate, "Security Groups", lo.Map(existingGroups, func(sg *types.SecurityGroup, _ int) string { return *sg.GroupId })...)
+	} else if len(opts.SecurityGroups) != 0 {
+		t := v.track(ActionCreate, "Security Groups")
+		securityGroups, err := v.createSecurityGroups(ctx, vpcID, subnets, opts)
+		vpcDetails.SecurityGroups = append(vpcDetails.SecurityGroups, securityGroups...)
+		if err := t.done(err, lo.Map(securityGroups, func(sg *types.SecurityGroup, _ int) string { return *sg.GroupId })...); err != nil {
+			return err
+		}
+	}
+
+	if len(opts.NetworkACLs) != 0 && len(vpcDetails.NetworkACLs) != 0 {
+		v.exists(ActionCreate, "Network ACLs", lo.Map(vpcDetails.NetworkACLs, func(acl *types.NetworkAcl, _ int) string { return *acl.NetworkAclId })...)
+	} else if len(opts.NetworkACLs) != 0 {
+		t := v.track(ActionCreate, "Network ACLs")
+		networkACLs, err := v.createNetworkACLs(ctx, vpcID, subnets, opts)
+		vpcDetails.NetworkACLs = networkACLs
+		if err := t.done(err, lo.Map(networkACLs, func(acl *types.NetworkAcl, _ int) string { return *acl.NetworkAclId })...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteStep deletes the resources with ids
+type deleteStep struct {
+	resource string
+	ids      []string
+	delete   func(context.Context, *Details, DeleteOptions) error
+}
+
+// Delete deletes a VPC and its subresources. Resources that were already deleted are skipped,
+// so a delete that was interrupted or timed out can be run again.
+func (v Client) Delete(ctx context.Context, opts DeleteOptions) (*Details, error) {
+	ctx, cancel := withTimeout(ctx, opts.Timeout)
+	defer cancel()
+	vpcDetails, err := v.delete(ctx, opts)
+	return vpcDetails, interrupted(ctx, err)
+}
+
+func (v Client) delete(ctx context.Context, opts DeleteOptions) (*Details, error) {
+	getOpts := GetOptions{Name: opts.Name, ID: opts.ID, Tags: opts.Tags, ResourceIDs: opts.ResourceIDs}
+	t := v.track(ActionFetch, "VPC details", getOpts.String())
+	vpcDetails, err := v.Get(ctx, getOpts)
+	if err := t.done(err); err != nil {
+		return vpcDetails, err
+	}
+	// Resources are deleted in dependency order, steps without any resources are skipped
+	steps := []deleteStep{
+		{"VPC Peering Connections", lo.Map(vpcDetails.PeeringConnections, func(pcx *types.VpcPeeringConnection, _ int) string { return *pcx.VpcPeeringConnectionId }), v.deletePeeringConnections},
+		{"Transit Gateway Attachment", optionalID(vpcDetails.TransitGatewayAttachment, func(a *types.TransitGatewayVpcAttachment) *string { return a.TransitGatewayAttachmentId }), v.deleteTGWAttachment},
+		{"NAT Gateways", lo.Map(allNATGWs(vpcDetails), func(natGW *types.NatGateway, _ int) string { return *natGW.NatGatewayId }), v.deleteNATGWs},
+		{"NAT Instance", optionalID(vpcDetails.NATInstance, func(instance *types.Instance) *string { return instance.InstanceId }), v.deleteNATInstance},
+		{"Private Hosted Zone", optionalID(vpcDetails.HostedZone, func(hz *route53types.HostedZone) *string { return hz.Id }), v.deleteHostedZone},
+		{"Internet Gateway", optionalID(vpcDetails.InternetGateway, func(igw *types.InternetGateway) *string { return igw.InternetGatewayId }), v.deleteIGW},
+		{"Route Tables", lo.Map(vpcDetails.RouteTables, func(rt *types.RouteTable, _ int) string { return *rt.RouteTableId }), v.deleteRouteTables},
+		{"Subnets", lo.Map(vpcDetails.Subnets, func(subnet *types.Subnet, _ int) string { return *subnet.SubnetId }), v.deleteSubnets},
+		{"Network ACLs", lo.Map(vpcDetails.NetworkACLs, func(acl *types.NetworkAcl, _ int) string { return *acl.NetworkAclId }), v.deleteNetworkACLs},
+		{"Security Groups", lo.Map(vpcDetails.SecurityGroups, func(sg *types.SecurityGroup, _ int) string { return *sg.GroupId }), v.deleteSecurityGroups},
+		{"DHCP Options", optionalID(vpcDetails.DHCPOptions, func(dhcp *types.DhcpOptions) *string { return dhcp.DhcpOptionsId }), v.deleteDHCPOptions},
+		{"VPC", optionalID(vpcDetails.VPC, func(vpc *types.Vpc) *string { return vpc.VpcId }), v.deleteVPC},
+	}
+	steps = lo.Filter(steps, func(step deleteStep, _ int) bool { return len(step.ids) != 0 })
+	v.plan(ActionDelete, lo.Map(steps, func(step deleteStep, _ int) string { return step.resource })...)
+	for _, step := range steps {
+		t := v.track(ActionDelete, step.resource, step.ids...)
+		if err := t.done(step.delete(ctx, vpcDetails, opts), step.ids...); err != nil {
 			return vpcDetails, err
 		}
 	}
 	return vpcDetails, nil
 }
 
+// optionalID returns the ID of an optional resource as a slice, which is empty if the resource or ID is nil
+func optionalID[T any](resource *T, id func(*T) *string) []string {
+	if resource == nil || id(resource) == nil {
+		return nil
+	}
+	return []string{*id(resource)}
+}
+
+// allNATGWs returns the live NAT gateways and the NAT gateways in the diagnostics
+func allNATGWs(vpcDetails *Details) []*types.NatGateway {
+	return lo.Flatten([][]*types.NatGateway{vpcDetails.NATGateways, lo.FromPtr(vpcDetails.Diagnostics).NATGateways})
+}
+
+// ipamPoolID returns the IPAM pool the VPC CIDR was allocated from or nil if it was not allocated from a pool
+func ipamPoolID(vpc *types.Vpc) *string {
+	if tag, ok := lo.Find(vpc.Tags, func(tag types.Tag) bool { return *tag.Key == IPAMPoolIDTagKey }); ok {
+		return tag.Value
+	}
+	return nil
+}
+
+// natGWStates returns the states of the described NAT gateways by ID
+func natGWStates(out *ec2.DescribeNatGatewaysOutput) map[string]string {
+	return lo.SliceToMap(out.NatGateways, func(natGW types.NatGateway) (string, string) { return *natGW.NatGatewayId, string(natGW.State) })
+}
+
+// instanceStates returns the states of the described instances by ID
+func instanceStates(out *ec2.DescribeInstancesOutput) map[string]string {
+	instances := lo.FlatMap(out.Reservations, func(reservation types.Reservation, _ int) []types.Instance { return reservation.Instances })
+	return lo.SliceToMap(instances, func(instance types.Instance) (string, string) {
+		return *instance.InstanceId, string(lo.FromPtr(instance.State).Name)
+	})
+}
+
 func (v Client) Get(ctx context.Context, opts GetOptions) (*Details, error) {
 	vpcDetails := &Details{}
 	vpc, err := v.getVPC(ctx, opts)
@@ -267,11 +872,52 @@ func (v Client) Get(ctx context.Context, opts GetOptions) (*Details, error) {
 		return vpcDetails, err
 	}
 
-	natGW, err := v.getNATGW(ctx, *vpc.VpcId, opts)
-	vpcDetails.NATGateway = natGW
+	natGWs, err := v.getNATGWs(ctx, *vpc.VpcId, opts)
+	if err != nil {
+		return vpcDetails, err
+	}
+	liveNATGWs, otherNATGWs := lo.FilterReject(natGWs, func(natGW *types.NatGateway, _ int) bool {
+		return natGW.State == types.NatGatewayStatePending || natGW.State == types.NatGatewayStateAvailable
+	})
+	vpcDetails.NATGateways = liveNATGWs
+	vpcDetails.NATGateway = lo.FirstOrEmpty(liveNATGWs)
+	if len(otherNATGWs) != 0 {
+		vpcDetails.Diagnostics = &Diagnostics{NATGateways: otherNATGWs}
+	}
+
+	natInstance, err := v.getNATInstance(ctx, *vpc.VpcId, opts)
+	vpcDetails.NATInstance = natInstance
+	if err != nil {
+		return vpcDetails, err
+	}
+
+	securityGroups, err := v.getSecurityGroups(ctx, *vpc.VpcId, opts)
+	vpcDetails.SecurityGroups = securityGroups
+	if err != nil {
+		return vpcDetails, err
+	}
+
+	networkACLs, err := v.getNetworkACLs(ctx, *vpc.VpcId, opts)
+	vpcDetails.NetworkACLs = networkACLs
+	if err != nil {
+		return vpcDetails, err
+	}
+
+	peeringConnections, err := v.getPeeringConnections(ctx, *vpc.VpcId, opts)
+	vpcDetails.PeeringConnections = peeringConnections
 	if err != nil {
 		return vpcDetails, err
 	}
+
+	tgwAttachment, err := v.getTGWAttachment(ctx, *vpc.VpcId, opts)
+	vpcDetails.TransitGatewayAttachment = tgwAttachment
+	if err != nil {
+		return vpcDetails, err
+	}
+
+	if err := v.getDNS(ctx, vpcDetails, opts); err != nil {
+		return vpcDetails, err
+	}
 	return vpcDetails, nil
 }
 
@@ -304,8 +950,8 @@ func (d Details) OutputEKSCTL() (string, error) {
 		VPC eksctlVPC `yaml:"vpc"`
 	}
 
-	privateSubnets := lo.Filter(d.Subnets, func(subnet *types.Subnet, _ int) bool { return !*subnet.MapPublicIpOnLaunch })
-	publicSubnets := lo.Filter(d.Subnets, func(subnet *types.Subnet, _ int) bool { return *subnet.MapPublicIpOnLaunch })
+	privateSubnets := lo.Filter(d.Subnets, func(subnet *types.Subnet, _ int) bool { return SubnetType(subnet) == SubnetTypePrivate })
+	publicSubnets := lo.Filter(d.Subnets, func(subnet *types.Subnet, _ int) bool { return SubnetType(subnet) == SubnetTypePublic })
 	private := map[string]map[string]string{}
 	public := map[string]map[string]string{}
 
@@ -338,3 +984,147 @@ func (d Details) OutputEKSCTL() (string, error) {
 	}
 	return b.String(), nil
 }
+
+// ToCreateOptions reconstructs the options to create a VPC with the same layout as the VPC in the details.
+// Tags managed by vpcctl or AWS and the Name tag are excluded from the user tags.
+func (d Details) ToCreateOptions() CreateOptions {
+	opts := CreateOptions{
+		Name:               lo.FromPtr(nameTag(d.VPC.Tags)),
+		CIDR:               *d.VPC.CidrBlock,
+		EnableDNSSupport:   aws.Bool(d.EnableDNSSupport),
+		EnableDNSHostnames: aws.Bool(d.EnableDNSHostnames),
+		Tags: lo.SliceToMap(lo.Reject(d.VPC.Tags, func(tag types.Tag, _ int) bool { return isManagedTag(*tag.Key) || *tag.Key == "Name" }),
+			func(tag types.Tag) (string, string) { return *tag.Key, *tag.Value }),
+	}
+	if poolID := ipamPoolID(d.VPC); poolID != nil {
+		opts.IPAMPoolID = *poolID
+		if prefix, err := netip.ParsePrefix(*d.VPC.CidrBlock); err == nil {
+			opts.IPAMNetmaskLength = int32(prefix.Bits()) //nolint:gosec // prefix length is at most 32
+		}
+	}
+	subnets := lo.Filter(d.Subnets, func(subnet *types.Subnet, _ int) bool { return SubnetType(subnet) != SubnetTypeTransitGateway })
+	// sort subnets so that the generated options are stable
+	sort.Slice(subnets, func(i, j int) bool { return *subnets[i].CidrBlock < *subnets[j].CidrBlock })
+	opts.Subnets = lo.Map(subnets, func(subnet *types.Subnet, _ int) CreateSubnetOptions {
+		return CreateSubnetOptions{
+			AZ:     *subnet.AvailabilityZone,
+			CIDR:   *subnet.CidrBlock,
+			Public: SubnetType(subnet) == SubnetTypePublic,
+		}
+	})
+	switch {
+	case len(d.NATGateways) != 0:
+		opts.NAT = NATModeGateway
+	case d.NATInstance != nil:
+		opts.NAT = NATModeInstance
+		opts.NATInstanceType = string(d.NATInstance.InstanceType)
+	default:
+		opts.NAT = NATModeNone
+	}
+	if d.TransitGatewayAttachment != nil {
+		// the attached subnets are of the tier the attachment was created with, unless they are dedicated subnets
+		tiers := lo.Uniq(lo.FilterMap(d.Subnets, func(subnet *types.Subnet, _ int) (string, bool) {
+			return SubnetType(subnet), lo.Contains(d.TransitGatewayAttachment.SubnetIds, *subnet.SubnetId) && SubnetType(subnet) != SubnetTypeTransitGateway
+		}))
+		opts.TransitGateway = &CreateTransitGatewayAttachmentOptions{
+			TransitGatewayID: *d.TransitGatewayAttachment.TransitGatewayId,
+			Tier:             lo.FirstOrEmpty(tiers),
+			DedicatedSubnets: lo.ContainsBy(d.Subnets, func(subnet *types.Subnet) bool { return SubnetType(subnet) == SubnetTypeTransitGateway }),
+			Routes: lo.Uniq(lo.FlatMap(d.RouteTables, func(rt *types.RouteTable, _ int) []string {
+				return lo.FilterMap(rt.Routes, func(route types.Route, _ int) (string, bool) {
+					return lo.FromPtr(route.DestinationCidrBlock), route.TransitGatewayId != nil
+				})
+			})),
+		}
+	}
+	if d.HostedZone != nil {
+		opts.PrivateHostedZone = strings.TrimSuffix(*d.HostedZone.Name, ".")
+	}
+	return opts
+}
+
+// SubnetType returns the PUBLIC, PRIVATE or TRANSIT_GATEWAY type of a subnet from its Type tag,
+// falling back to whether public IPs are mapped on launch for subnets without the tag.
+func SubnetType(subnet *types.Subnet) string {
+	if tag, ok := lo.Find(subnet.Tags, func(tag types.Tag) bool { return *tag.Key == SubnetTypeTagKey }); ok {
+		return *tag.Value
+	}
+	return lo.Ternary(lo.FromPtr(subnet.MapPublicIpOnLaunch), SubnetTypePublic, SubnetTypePrivate)
+}
+
+// nameTag returns the value of the Name tag or nil if there is no Name tag
+func nameTag(tags []types.Tag) *string {
+	if tag, ok := lo.Find(tags, func(tag types.Tag) bool { return *tag.Key == "Name" }); ok {
+		return tag.Value
+	}
+	return nil
+}
+
+// isManagedTag returns true for tags that are set by vpcctl or AWS rather than the user
+func isManagedTag(key string) bool {
+	return lo.Contains([]string{CreatedByTagKey, IPAMPoolIDTagKey, SubnetTypeTagKey, NATInstanceTagKey}, key) || strings.HasPrefix(key, "aws:")
+}
+
+// paginator is implemented by the SDK's paginators, e.g. ec2.DescribeVpcsPaginator
+type paginator[Output any, Options any] interface {
+	HasMorePages() bool
+	NextPage(context.Context, ...func(*Options)) (Output, error)
+}
+
+// allPages collects the items of every page of the paginator
+func allPages[Output any, Options any, Item any](ctx context.Context, p paginator[Output, Options], items func(Output) []Item) ([]Item, error) {
+	var all []Item
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return all, err
+		}
+		all = append(all, items(page)...)
+	}
+	return all, nil
+}
+
+// poll calls condition every interval until it returns true, an error, or the timeout expires.
+// It is used for resources that do not have an SDK waiter.
+func poll(ctx context.Context, interval time.Duration, timeout time.Duration, condition func(context.Context) (bool, error)) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		done, err := condition(ctx)
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("exceeded max wait time: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// withTimeout returns a context that is cancelled after timeout, or ctx when timeout is 0
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout == 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// interrupted wraps err with the context's error when the context was cancelled or timed out,
+// since the SDK waiters report their own timeout instead of the context's
+func interrupted(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil || errors.Is(err, ctx.Err()) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ctx.Err(), err)
+}
+
+// stepTimeout returns the step timeout or DefaultStepTimeout when it is 0
+func stepTimeout(timeout time.Duration) time.Duration {
+	return lo.CoalesceOrEmpty(timeout, DefaultStepTimeout)
+}